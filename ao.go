@@ -0,0 +1,180 @@
+package tcpoption
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"syscall"
+)
+
+const (
+	AOKeyMaxLen int = 80 // TCP_AO_MAXKEYLEN
+
+	AOAlgHMACSHA1   string = "hmac(sha1)"
+	AOAlgHMACSHA256 string = "hmac(sha256)"
+	AOAlgCMACAES128 string = "cmac(aes128)"
+)
+
+var ErrAONotSupported = errors.New("tcp-ao requires kernel 6.7+ with CONFIG_TCP_AO")
+
+// AOKey is RFC 5925 master key tuple, (Peer, SendID, RecvID) identifies the key
+type AOKey struct {
+	Peer           netip.Prefix
+	SendID         uint8 // KeyID put in outgoing segments
+	RecvID         uint8 // KeyID expected in incoming segments
+	Algorithm      string
+	Key            []byte
+	MACLen         int // 0 uses algorithm default (12 bytes for hmac(sha1))
+	Ifindex        int // VRF (l3mdev) device index, 0 matches any
+	ExcludeOptions bool
+}
+
+type AOInfo struct {
+	CurrentKey     uint8 // SendID used for outgoing segments
+	RNextKey       uint8 // RecvID requested from peer
+	Required       bool  // segments without TCP-AO are dropped
+	AcceptICMPs    bool
+	PktGood        uint64
+	PktBad         uint64
+	PktKeyNotFound uint64
+	PktAORequired  uint64
+	PktDroppedICMP uint64
+}
+
+type AOKeyInfo struct {
+	Peer      netip.Prefix
+	SendID    uint8
+	RecvID    uint8
+	Algorithm string
+	MACLen    int
+	Ifindex   int
+	Current   bool
+	RNext     bool
+	PktGood   uint64
+	PktBad    uint64
+}
+
+// AddAOKey adds key on a listener or conn (*net.TCPListener, *net.TCPConn), requires kernel 6.7+ with CONFIG_TCP_AO.
+// without current/rnext the last key added matching the peer becomes current and rnext on connect,
+// for client side add keys before SYN with Dialer and Config.AOKeys which selects AOKeys[0].
+// current/rnext select key immediately (not on listeners).
+func AddAOKey(c syscall.Conn, key AOKey, current, rnext bool) error {
+	return getFd(c, func(fd int) error {
+		return AddAOKeyFd(fd, key, current, rnext)
+	})
+}
+
+func AddAOKeyFd(fd int, key AOKey, current, rnext bool) error {
+	if err := validateAOKey(key); err != nil {
+		return err
+	}
+	if key.Algorithm == "" {
+		key.Algorithm = AOAlgHMACSHA1
+	}
+	key.Peer = key.Peer.Masked()
+	return aoError(setsockoptAOAddKey(fd, key, current, rnext))
+}
+
+// DeleteAOKey removes the key, kernel refuses to delete current or rnext key of a conn (EBUSY).
+// after a rollover both sides must move current and rnext off the old key before deleting it.
+func DeleteAOKey(c syscall.Conn, peer netip.Prefix, sendID, recvID uint8, ifindex int) error {
+	return getFd(c, func(fd int) error {
+		return DeleteAOKeyFd(fd, peer, sendID, recvID, ifindex)
+	})
+}
+
+func DeleteAOKeyFd(fd int, peer netip.Prefix, sendID, recvID uint8, ifindex int) error {
+	if peer.IsValid() != true {
+		return fmt.Errorf("invalid ao peer: %s", peer)
+	}
+	return aoError(setsockoptAODelKey(fd, peer.Masked(), sendID, recvID, ifindex))
+}
+
+// SetAOCurrentKey switches the key used for outgoing segments to sendID
+func SetAOCurrentKey(c syscall.Conn, sendID uint8) error {
+	return getFd(c, func(fd int) error {
+		return aoError(setsockoptAOInfo(fd, int(sendID), -1))
+	})
+}
+
+// SetAORNextKey asks the peer to switch its current key to the one matching recvID
+func SetAORNextKey(c syscall.Conn, recvID uint8) error {
+	return getFd(c, func(fd int) error {
+		return aoError(setsockoptAOInfo(fd, -1, int(recvID)))
+	})
+}
+
+// SetAORequired drops segments without TCP-AO (ao_required) once keys are installed
+func SetAORequired(c syscall.Conn, required bool) error {
+	return getFd(c, func(fd int) error {
+		return SetAORequiredFd(fd, required)
+	})
+}
+
+func SetAORequiredFd(fd int, required bool) error {
+	return aoError(setsockoptAORequired(fd, required))
+}
+
+// RotateAOKey installs next, sends with it and requests the peer to do the same (RNext).
+// peer must already have the matching key and follows RNext for current only, so the peer
+// also has to SetAORNextKey (or RotateAOKey) to next. delete the previous one with DeleteAOKey
+// on both sides once GetAOInfo shows current and rnext on next and GetAOKeys shows segments verified by next.
+func RotateAOKey(c syscall.Conn, next AOKey) error {
+	return getFd(c, func(fd int) error {
+		return AddAOKeyFd(fd, next, true, true)
+	})
+}
+
+func GetAOInfo(c syscall.Conn) (AOInfo, error) {
+	info := AOInfo{}
+	if err := getFd(c, func(fd int) error {
+		v, err := getsockoptAOInfo(fd)
+		if err != nil {
+			return aoError(err)
+		}
+		info = v
+		return nil
+	}); err != nil {
+		return AOInfo{}, err
+	}
+	return info, nil
+}
+
+// GetAOKeys returns every key installed with its packet counters
+func GetAOKeys(c syscall.Conn) ([]AOKeyInfo, error) {
+	var keys []AOKeyInfo
+	if err := getFd(c, func(fd int) error {
+		v, err := getsockoptAOKeys(fd)
+		if err != nil {
+			return aoError(err)
+		}
+		keys = v
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func validateAOKey(key AOKey) error {
+	if key.Peer.IsValid() != true {
+		return fmt.Errorf("invalid ao peer: %s", key.Peer)
+	}
+	if len(key.Key) < 1 {
+		return fmt.Errorf("ao key is empty")
+	}
+	if AOKeyMaxLen < len(key.Key) {
+		return fmt.Errorf("ao key length %d exceeds %d", len(key.Key), AOKeyMaxLen)
+	}
+	if key.MACLen < 0 || 255 < key.MACLen {
+		return fmt.Errorf("ao mac length %d out of range", key.MACLen)
+	}
+	return nil
+}
+
+func aoError(err error) error {
+	if err != nil && errors.Is(err, syscall.ENOPROTOOPT) {
+		return fmt.Errorf("%w: %v", ErrAONotSupported, err)
+	}
+	return err
+}
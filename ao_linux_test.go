@@ -0,0 +1,223 @@
+package tcpoption
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+func TestAOStructLayout(t *testing.T) {
+	add := tcpAOAdd{}
+	del := tcpAODel{}
+	info := tcpAOInfoOpt{}
+	get := tcpAOGetsockopt{}
+	tests := []struct {
+		name   string
+		actual uintptr
+		expect uintptr
+	}{
+		{"sizeof(tcp_md5sig)", unsafe.Sizeof(tcpMD5Sig{}), 216},
+		{"sizeof(tcp_ao_add)", unsafe.Sizeof(add), 288},
+		{"tcp_ao_add.ifindex", unsafe.Offsetof(add.Ifindex), 192},
+		{"tcp_ao_add.prefix", unsafe.Offsetof(add.Prefix), 202},
+		{"tcp_ao_add.key", unsafe.Offsetof(add.Key), 208},
+		{"sizeof(tcp_ao_del)", unsafe.Sizeof(del), 144},
+		{"tcp_ao_del.prefix", unsafe.Offsetof(del.Prefix), 138},
+		{"tcp_ao_del.keyflags", unsafe.Offsetof(del.Keyflags), 143},
+		{"sizeof(tcp_ao_info_opt)", unsafe.Sizeof(info), 48},
+		{"tcp_ao_info_opt.pkt_good", unsafe.Offsetof(info.PktGood), 8},
+		{"sizeof(tcp_ao_getsockopt)", unsafe.Sizeof(get), 304},
+		{"tcp_ao_getsockopt.nkeys", unsafe.Offsetof(get.Nkeys), 272},
+		{"tcp_ao_getsockopt.ifindex", unsafe.Offsetof(get.Ifindex), 280},
+		{"tcp_ao_getsockopt.pkt_good", unsafe.Offsetof(get.PktGood), 288},
+	}
+	for _, tc := range tests {
+		if tc.actual != tc.expect {
+			t.Errorf("%s expect:%d actual:%d", tc.name, tc.expect, tc.actual)
+		}
+	}
+}
+
+func aoKeys(secret1, secret2 string) []AOKey {
+	peer := netip.MustParsePrefix("127.0.0.1/32")
+	return []AOKey{
+		{Peer: peer, SendID: 1, RecvID: 1, Algorithm: AOAlgHMACSHA1, Key: []byte(secret1)},
+		{Peer: peer, SendID: 2, RecvID: 2, Algorithm: AOAlgHMACSHA1, Key: []byte(secret2)},
+	}
+}
+
+func setupAOPair(t *testing.T, serverKeys, clientKeys []AOKey) (net.Conn, net.Conn) {
+	listener, err := Listen(context.TODO(), "tcp4", "127.0.0.1:0", Config{AOKeys: serverKeys})
+	if err != nil {
+		if errors.Is(err, ErrAONotSupported) {
+			t.Skipf("tcp-ao not supported: %+v", err)
+		}
+		t.Fatalf("listen err: %+v", err)
+	}
+	defer listener.Close()
+	accepted := acceptLoop(t, listener)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	client, err := Dial(ctx, "tcp4", listener.Addr().String(), Config{AOKeys: clientKeys})
+	if err != nil {
+		return nil, nil
+	}
+	return client, <-accepted
+}
+
+func pingPong(t *testing.T, client, server net.Conn) {
+	msg := []byte("keepalive")
+	if _, err := client.Write(msg); err != nil {
+		t.Fatalf("write err: %+v", err)
+	}
+	server.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, len(msg))
+	if _, err := io.ReadFull(server, buf); err != nil {
+		t.Fatalf("read err: %+v", err)
+	}
+	if _, err := server.Write(buf); err != nil {
+		t.Fatalf("write err: %+v", err)
+	}
+	client.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := io.ReadFull(client, buf); err != nil {
+		t.Fatalf("read err: %+v", err)
+	}
+}
+
+func TestAOKeyRollover(t *testing.T) {
+	keys := aoKeys("first-secret", "second-secret")
+	client, server := setupAOPair(t, keys, keys)
+	if client == nil {
+		t.Fatalf("signed dial failed")
+	}
+	defer client.Close()
+	defer server.Close()
+	pingPong(t, client, server)
+
+	c, s := client.(*net.TCPConn), server.(*net.TCPConn)
+	info, err := GetAOInfo(c)
+	if err != nil {
+		t.Fatalf("ao info err: %+v", err)
+	}
+	if info.CurrentKey != 1 || info.RNextKey != 1 {
+		t.Errorf("first key must be current: %+v", info)
+	}
+
+	if err := SetAOCurrentKey(c, 2); err != nil {
+		t.Fatalf("set current err: %+v", err)
+	}
+	if err := SetAORNextKey(c, 2); err != nil {
+		t.Fatalf("set rnext err: %+v", err)
+	}
+	pingPong(t, client, server)
+
+	// server follows RNext requested by client
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		pingPong(t, client, server)
+		if info, _ := GetAOInfo(s); info.CurrentKey == 2 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if info, _ := GetAOInfo(s); info.CurrentKey != 2 {
+		t.Fatalf("server must switch to key 2: %+v", info)
+	}
+	// kernel refuses to delete the rnext key (EBUSY), server still requests key 1
+	if err := SetAORNextKey(s, 2); err != nil {
+		t.Fatalf("server set rnext err: %+v", err)
+	}
+	pingPong(t, client, server)
+	if info, _ := GetAOInfo(s); info.CurrentKey != 2 || info.RNextKey != 2 {
+		t.Fatalf("server must use key 2 only: %+v", info)
+	}
+
+	if err := DeleteAOKey(c, keys[0].Peer, 1, 1, 0); err != nil {
+		t.Fatalf("client delete err: %+v", err)
+	}
+	if err := DeleteAOKey(s, keys[0].Peer, 1, 1, 0); err != nil {
+		t.Fatalf("server delete err: %+v", err)
+	}
+	pingPong(t, client, server)
+
+	list, err := GetAOKeys(c)
+	if err != nil {
+		t.Fatalf("ao keys err: %+v", err)
+	}
+	if len(list) != 1 || list[0].SendID != 2 || list[0].Current != true {
+		t.Fatalf("only key 2 must remain as current: %+v", list)
+	}
+	if list[0].PktGood < 1 {
+		t.Errorf("key 2 must verify segments: %+v", list[0])
+	}
+	if list[0].Peer != keys[1].Peer || list[0].Algorithm != AOAlgHMACSHA1 {
+		t.Errorf("unexpected key: %+v", list[0])
+	}
+}
+
+func TestAORequiredKeptOnRollover(t *testing.T) {
+	keys := aoKeys("first-secret", "second-secret")
+	client, server := setupAOPair(t, keys, keys)
+	if client == nil {
+		t.Fatalf("signed dial failed")
+	}
+	defer client.Close()
+	defer server.Close()
+
+	c := client.(*net.TCPConn)
+	if err := SetAORequired(c, true); err != nil {
+		t.Fatalf("set required err: %+v", err)
+	}
+	if err := SetAOCurrentKey(c, 2); err != nil {
+		t.Fatalf("set current err: %+v", err)
+	}
+	if err := SetAORNextKey(c, 2); err != nil {
+		t.Fatalf("set rnext err: %+v", err)
+	}
+	pingPong(t, client, server)
+
+	info, err := GetAOInfo(c)
+	if err != nil {
+		t.Fatalf("ao info err: %+v", err)
+	}
+	if info.Required != true {
+		t.Errorf("ao_required must be kept after rollover: %+v", info)
+	}
+	if info.CurrentKey != 2 || info.RNextKey != 2 {
+		t.Errorf("key 2 must be current: %+v", info)
+	}
+
+	if err := SetAORequired(c, false); err != nil {
+		t.Fatalf("clear required err: %+v", err)
+	}
+	if info, _ := GetAOInfo(c); info.Required || info.CurrentKey != 2 {
+		t.Errorf("ao_required must be cleared keeping current key: %+v", info)
+	}
+}
+
+func TestAOKeyMismatch(t *testing.T) {
+	client, server := setupAOPair(t, aoKeys("server-secret", "x")[:1], aoKeys("client-secret", "x")[:1])
+	if client != nil {
+		client.Close()
+		server.Close()
+		t.Fatalf("mismatched key must not connect")
+	}
+}
+
+func TestAOKeyValidate(t *testing.T) {
+	if err := validateAOKey(AOKey{Key: []byte("k")}); err == nil {
+		t.Errorf("invalid peer must fail")
+	}
+	if err := validateAOKey(AOKey{Peer: netip.MustParsePrefix("127.0.0.1/32")}); err == nil {
+		t.Errorf("empty key must fail")
+	}
+	if err := validateAOKey(AOKey{Peer: netip.MustParsePrefix("127.0.0.1/32"), Key: make([]byte, AOKeyMaxLen+1)}); err == nil {
+		t.Errorf("too long key must fail")
+	}
+}
@@ -0,0 +1,105 @@
+package tcpoption
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// CorkWriter coalesces writes into full segments with TCP_CORK.
+// it corks on first Write and uncorks on Flush or after maxHold elapsed,
+// then restores the TCP_NODELAY that conn had before corking.
+type CorkWriter struct {
+	net.Conn
+
+	maxHold time.Duration
+	mutex   sync.Mutex
+	corked  bool
+	noDelay int
+	timer   *time.Timer
+}
+
+// maxHold 0 holds segments until Flush is called.
+func NewCorkWriter(conn net.Conn, maxHold time.Duration) *CorkWriter {
+	return &CorkWriter{
+		Conn:    conn,
+		maxHold: maxHold,
+	}
+}
+
+func (w *CorkWriter) Write(p []byte) (int, error) {
+	if err := w.cork(); err != nil {
+		return 0, err
+	}
+	return w.Conn.Write(p)
+}
+
+func (w *CorkWriter) Flush() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return w.uncorkLocked()
+}
+
+func (w *CorkWriter) Close() error {
+	flushErr := w.Flush()
+	if err := w.Conn.Close(); err != nil {
+		return err
+	}
+	return flushErr
+}
+
+func (w *CorkWriter) cork() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.corked {
+		return nil
+	}
+	c, ok := w.Conn.(*net.TCPConn)
+	if ok != true {
+		return nil
+	}
+	if err := getFd(c, func(fd int) error {
+		noDelay, err := getsockoptNoDelay(fd)
+		if err != nil {
+			return err
+		}
+		w.noDelay = noDelay
+		return setsockoptCork(fd, 1)
+	}); err != nil {
+		return err
+	}
+	w.corked = true
+
+	if 0 < w.maxHold {
+		if w.timer == nil {
+			w.timer = time.AfterFunc(w.maxHold, w.flushByTimer)
+		} else {
+			w.timer.Reset(w.maxHold)
+		}
+	}
+	return nil
+}
+
+func (w *CorkWriter) flushByTimer() {
+	w.Flush()
+}
+
+func (w *CorkWriter) uncorkLocked() error {
+	if w.corked != true {
+		return nil
+	}
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	w.corked = false
+
+	c := w.Conn.(*net.TCPConn)
+	return getFd(c, func(fd int) error {
+		if err := setsockoptCork(fd, 0); err != nil {
+			return err
+		}
+		return setsockoptNoDelay(c, w.noDelay != 0)
+	})
+}
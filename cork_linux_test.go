@@ -0,0 +1,175 @@
+package tcpoption
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestSetCork(t *testing.T) {
+	client, server := setupConnPair(t)
+	defer client.Close()
+	defer server.Close()
+
+	if err := SetCork(client, true); err != nil {
+		t.Fatalf("cork set err: %+v", err)
+	}
+	if err := getFd(client.(*net.TCPConn), func(fd int) error {
+		if v, err := getsockoptCork(fd); err != nil {
+			t.Errorf("cork get err: %+v", err)
+		} else {
+			if v != 1 {
+				t.Errorf("enable cork: %d", v)
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Errorf("must no error: %+v", err)
+	}
+}
+
+func TestCorkWriter(t *testing.T) {
+	t.Run("flush", func(tt *testing.T) {
+		client, server := setupConnPair(tt)
+		defer client.Close()
+		defer server.Close()
+
+		w := NewCorkWriter(client, 0)
+		if _, err := w.Write([]byte("HEAD")); err != nil {
+			tt.Fatalf("write err: %+v", err)
+		}
+
+		buf := make([]byte, 4)
+		server.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+		if n, err := server.Read(buf); err == nil {
+			tt.Errorf("corked segment must be held: %q", buf[:n])
+		}
+
+		if err := w.Flush(); err != nil {
+			tt.Fatalf("flush err: %+v", err)
+		}
+		server.SetReadDeadline(time.Now().Add(time.Second))
+		if _, err := io.ReadFull(server, buf); err != nil {
+			tt.Fatalf("read err: %+v", err)
+		}
+		if string(buf) != "HEAD" {
+			tt.Errorf("unexpected data: %q", buf)
+		}
+
+		if err := getFd(client.(*net.TCPConn), func(fd int) error {
+			if v, err := getsockoptCork(fd); err != nil {
+				tt.Errorf("cork get err: %+v", err)
+			} else {
+				if v != 0 {
+					tt.Errorf("flush must uncork: %d", v)
+				}
+			}
+			if v, err := getsockoptNoDelay(fd); err != nil {
+				tt.Errorf("nodelay get err: %+v", err)
+			} else {
+				if v != 1 {
+					tt.Errorf("flush must restore nodelay: %d", v)
+				}
+			}
+			return nil
+		}); err != nil {
+			tt.Errorf("must no error: %+v", err)
+		}
+	})
+	t.Run("max_hold", func(tt *testing.T) {
+		client, server := setupConnPair(tt)
+		defer client.Close()
+		defer server.Close()
+
+		w := NewCorkWriter(client, 20*time.Millisecond)
+		if _, err := w.Write([]byte("HEAD")); err != nil {
+			tt.Fatalf("write err: %+v", err)
+		}
+
+		buf := make([]byte, 4)
+		server.SetReadDeadline(time.Now().Add(time.Second))
+		if _, err := io.ReadFull(server, buf); err != nil {
+			tt.Fatalf("max hold must uncork: %+v", err)
+		}
+		if string(buf) != "HEAD" {
+			tt.Errorf("unexpected data: %q", buf)
+		}
+	})
+}
+
+func benchmarkResponse(b *testing.B, write func(conn net.Conn, header []byte, chunks [][]byte) error) {
+	client, server := setupConnPair(b)
+	defer client.Close()
+	defer server.Close()
+
+	header := make([]byte, 128)
+	chunks := [][]byte{
+		make([]byte, 700),
+		make([]byte, 700),
+		make([]byte, 700),
+	}
+	size := len(header) + (len(chunks) * 700)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		buf := make([]byte, size)
+		ack := []byte{0}
+		for {
+			if _, err := io.ReadFull(server, buf); err != nil {
+				return
+			}
+			if _, err := server.Write(ack); err != nil {
+				return
+			}
+		}
+	}()
+
+	ack := make([]byte, 1)
+	b.ResetTimer()
+	for i := 0; i < b.N; i += 1 {
+		if err := write(client, header, chunks); err != nil {
+			b.Fatalf("write err: %+v", err)
+		}
+		if _, err := io.ReadFull(client, ack); err != nil {
+			b.Fatalf("ack err: %+v", err)
+		}
+	}
+	b.StopTimer()
+	client.Close()
+	<-done
+}
+
+func BenchmarkCorkWriter(b *testing.B) {
+	b.Run("nodelay", func(tb *testing.B) {
+		benchmarkResponse(tb, func(conn net.Conn, header []byte, chunks [][]byte) error {
+			if err := SetNoDelay(conn, true); err != nil {
+				return err
+			}
+			if _, err := conn.Write(header); err != nil {
+				return err
+			}
+			for _, chunk := range chunks {
+				if _, err := conn.Write(chunk); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+	b.Run("cork", func(tb *testing.B) {
+		benchmarkResponse(tb, func(conn net.Conn, header []byte, chunks [][]byte) error {
+			w := NewCorkWriter(conn, 0)
+			if _, err := w.Write(header); err != nil {
+				return err
+			}
+			for _, chunk := range chunks {
+				if _, err := w.Write(chunk); err != nil {
+					return err
+				}
+			}
+			return w.Flush()
+		})
+	})
+}
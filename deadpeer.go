@@ -0,0 +1,116 @@
+package tcpoption
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+// include/net/tcp.h
+const (
+	MaxKeepAliveIdle     int = 32767 // MAX_TCP_KEEPIDLE
+	MaxKeepAliveInterval int = 32767 // MAX_TCP_KEEPINTVL
+	MaxKeepAliveProbes   int = 127   // MAX_TCP_KEEPCNT
+	maxUserTimeoutMsec   int = 1<<31 - 1
+
+	DefaultDeadPeerProbes int = 3
+)
+
+type DeadPeerOptions struct {
+	Probes   int           // keepalive probes, DefaultDeadPeerProbes if 0
+	Interval time.Duration // keepalive probe interval, derived from budget if 0
+}
+
+type DeadPeerParams struct {
+	KeepAliveIdle     time.Duration
+	KeepAliveInterval time.Duration
+	KeepAliveProbes   int
+	UserTimeout       time.Duration
+}
+
+// DeadPeerTimeoutParams derives keepalive and TCP_USER_TIMEOUT so that dead peer is detected within total,
+// both when conn is idle (keepalive) and when data is in flight (retransmission).
+// with TCP_USER_TIMEOUT set linux aborts at the first keepalive probe after UserTimeout elapsed,
+// so idle + probes * interval is made equal to UserTimeout.
+func DeadPeerTimeoutParams(total time.Duration, opts DeadPeerOptions) (DeadPeerParams, error) {
+	budget := IntSecond(total)
+	if budget < 2 {
+		return DeadPeerParams{}, fmt.Errorf("dead peer budget %s is less than 2s (keepalive idle and interval are seconds)", total)
+	}
+	if maxUserTimeoutMsec/1000 < budget {
+		return DeadPeerParams{}, fmt.Errorf("dead peer budget %s exceeds TCP_USER_TIMEOUT limit", total)
+	}
+
+	probes := opts.Probes
+	if probes == 0 {
+		probes = DefaultDeadPeerProbes
+	}
+	if probes < 1 || MaxKeepAliveProbes < probes {
+		return DeadPeerParams{}, fmt.Errorf("keepalive probes %d out of range 1-%d", probes, MaxKeepAliveProbes)
+	}
+
+	interval := 0
+	if 0 < opts.Interval {
+		if time.Duration(IntSecond(opts.Interval))*time.Second != opts.Interval {
+			return DeadPeerParams{}, fmt.Errorf("keepalive interval %s is not whole seconds", opts.Interval)
+		}
+		interval = IntSecond(opts.Interval)
+	} else {
+		if (budget - 1) < probes {
+			probes = budget - 1
+		}
+		interval = (budget / 2) / probes
+		if interval < 1 {
+			interval = 1
+		}
+		if idle := budget - (probes * interval); MaxKeepAliveIdle < idle {
+			interval = (budget - MaxKeepAliveIdle + probes - 1) / probes
+		}
+	}
+	if interval < 1 || MaxKeepAliveInterval < interval {
+		return DeadPeerParams{}, fmt.Errorf("keepalive interval %ds out of range 1-%d", interval, MaxKeepAliveInterval)
+	}
+
+	idle := budget - (probes * interval)
+	if idle < 1 {
+		return DeadPeerParams{}, fmt.Errorf("dead peer budget %s is too small for %d probes every %ds", total, probes, interval)
+	}
+	if MaxKeepAliveIdle < idle {
+		return DeadPeerParams{}, fmt.Errorf("dead peer budget %s needs keepalive idle %ds over %d", total, idle, MaxKeepAliveIdle)
+	}
+
+	return DeadPeerParams{
+		KeepAliveIdle:     time.Duration(idle) * time.Second,
+		KeepAliveInterval: time.Duration(interval) * time.Second,
+		KeepAliveProbes:   probes,
+		UserTimeout:       time.Duration(budget) * time.Second,
+	}, nil
+}
+
+func SetDeadPeerTimeout(conn net.Conn, total time.Duration, opts DeadPeerOptions) (DeadPeerParams, error) {
+	params, err := DeadPeerTimeoutParams(total, opts)
+	if err != nil {
+		return DeadPeerParams{}, err
+	}
+	if err := KeepAlive(conn, true, params.KeepAliveIdle, params.KeepAliveInterval, params.KeepAliveProbes); err != nil {
+		return DeadPeerParams{}, err
+	}
+	if err := SetUserTimeout(conn, params.UserTimeout); err != nil {
+		return DeadPeerParams{}, err
+	}
+	return params, nil
+}
+
+// SetUserTimeout bounds how long transmitted data may remain unacknowledged before conn is dropped.
+func SetUserTimeout(conn net.Conn, d time.Duration) error {
+	if c, ok := conn.(*net.TCPConn); ok {
+		return getFd(c, func(fd int) error {
+			return setsockoptUserTimeout(fd, d)
+		})
+	}
+	return nil
+}
+
+func SetUserTimeoutFd(fd int, d time.Duration) error {
+	return setsockoptUserTimeout(fd, d)
+}
@@ -0,0 +1,57 @@
+package tcpoption
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestSetDeadPeerTimeout(t *testing.T) {
+	client, server := setupConnPair(t)
+	defer client.Close()
+	defer server.Close()
+
+	p, err := SetDeadPeerTimeout(client, 45*time.Second, DeadPeerOptions{})
+	if err != nil {
+		t.Fatalf("dead peer timeout set err: %+v", err)
+	}
+	t.Logf("params: %+v", p)
+
+	if err := getFd(client.(*net.TCPConn), func(fd int) error {
+		if v, err := getsockoptKeepAliveIdle(fd); err != nil {
+			t.Errorf("keepalive get idle err:%+v", err)
+		} else {
+			if v != IntSecond(p.KeepAliveIdle) {
+				t.Errorf("keepalive idle %s, actual:%d", p.KeepAliveIdle, v)
+			}
+		}
+		if v, err := getsockoptKeepAliveInterval(fd); err != nil {
+			t.Errorf("keepalive get interval err:%+v", err)
+		} else {
+			if v != IntSecond(p.KeepAliveInterval) {
+				t.Errorf("keepalive interval %s, actual:%d", p.KeepAliveInterval, v)
+			}
+		}
+		if v, err := getsockoptKeepAliveProbes(fd); err != nil {
+			t.Errorf("keepalive get probes err:%+v", err)
+		} else {
+			if v != p.KeepAliveProbes {
+				t.Errorf("keepalive probes %d, actual:%d", p.KeepAliveProbes, v)
+			}
+		}
+		if v, err := getsockoptUserTimeout(fd); err != nil {
+			t.Errorf("user_timeout get err:%+v", err)
+		} else {
+			if v != 45000 {
+				t.Errorf("user_timeout 45000ms, actual:%d", v)
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Errorf("must no error: %+v", err)
+	}
+
+	if _, err := SetDeadPeerTimeout(client, time.Second, DeadPeerOptions{}); err == nil {
+		t.Errorf("1s budget must be refused")
+	}
+}
@@ -0,0 +1,93 @@
+package tcpoption
+
+import (
+	"net"
+	"syscall"
+)
+
+// BindToDevice pins conn to the interface (or VRF master) name, empty name removes the binding.
+// binding must be in place before connect to steer the SYN, use Dialer/ListenConfig with Config.BindToDevice.
+// kernel 5.7+ allows unprivileged bind on a socket not yet bound to a device,
+// rebinding or older kernels require CAP_NET_RAW, reported as PrivilegeError.
+func BindToDevice(conn net.Conn, name string) error {
+	if c, ok := conn.(*net.TCPConn); ok {
+		return getFd(c, func(fd int) error {
+			return BindToDeviceFd(fd, name)
+		})
+	}
+	return nil
+}
+
+func BindToDeviceFd(fd int, name string) error {
+	return setsockoptBindToDevice(fd, name)
+}
+
+// BindToIfindex pins conn by interface index, 0 removes the binding.
+// same privilege rules as BindToDevice apply.
+func BindToIfindex(conn net.Conn, ifindex int) error {
+	if c, ok := conn.(*net.TCPConn); ok {
+		return getFd(c, func(fd int) error {
+			return BindToIfindexFd(fd, ifindex)
+		})
+	}
+	return nil
+}
+
+func BindToIfindexFd(fd int, ifindex int) error {
+	return setsockoptBindToIfindex(fd, ifindex)
+}
+
+// GetBindToDevice returns the interface name conn is bound to, empty if unbound
+func GetBindToDevice(conn net.Conn) (string, error) {
+	if c, ok := conn.(*net.TCPConn); ok {
+		name := ""
+		if err := getFd(c, func(fd int) error {
+			v, err := GetBindToDeviceFd(fd)
+			if err != nil {
+				return err
+			}
+			name = v
+			return nil
+		}); err != nil {
+			return "", err
+		}
+		return name, nil
+	}
+	return "", nil
+}
+
+func GetBindToDeviceFd(fd int) (string, error) {
+	return getsockoptBindToDevice(fd)
+}
+
+// GetBindToIfindex returns the interface index conn is bound to, 0 if unbound
+func GetBindToIfindex(conn net.Conn) (int, error) {
+	if c, ok := conn.(*net.TCPConn); ok {
+		ifindex := 0
+		if err := getFd(c, func(fd int) error {
+			v, err := GetBindToIfindexFd(fd)
+			if err != nil {
+				return err
+			}
+			ifindex = v
+			return nil
+		}); err != nil {
+			return 0, err
+		}
+		return ifindex, nil
+	}
+	return 0, nil
+}
+
+func GetBindToIfindexFd(fd int) (int, error) {
+	return getsockoptBindToIfindex(fd)
+}
+
+func isIPv6Socket(fd int) (bool, error) {
+	sa, err := syscall.Getsockname(fd)
+	if err != nil {
+		return false, err
+	}
+	_, ok := sa.(*syscall.SockaddrInet6)
+	return ok, nil
+}
@@ -0,0 +1,131 @@
+package tcpoption
+
+import (
+	"context"
+	"net"
+	"testing"
+)
+
+func loopbackInterface(t *testing.T) *net.Interface {
+	ifs, err := net.Interfaces()
+	if err != nil {
+		t.Fatalf("interfaces err: %+v", err)
+	}
+	for _, ifi := range ifs {
+		if (ifi.Flags & net.FlagLoopback) != 0 {
+			return &ifi
+		}
+	}
+	t.Skipf("no loopback interface")
+	return nil
+}
+
+func TestBindToDevice(t *testing.T) {
+	lo := loopbackInterface(t)
+
+	t.Run("dialer", func(tt *testing.T) {
+		cfg := Config{BindToDevice: lo.Name}
+		listener, err := Listen(context.TODO(), "tcp", "127.0.0.1:0", cfg)
+		if err != nil {
+			if IsPrivilegeError(err) {
+				tt.Skipf("not privileged: %+v", err)
+			}
+			tt.Fatalf("listen err: %+v", err)
+		}
+		defer listener.Close()
+
+		accepted := make(chan net.Conn, 1)
+		go func() {
+			conn, err := listener.Accept()
+			if err != nil {
+				close(accepted)
+				return
+			}
+			accepted <- conn
+		}()
+
+		client, err := Dial(context.TODO(), "tcp", listener.Addr().String(), cfg)
+		if err != nil {
+			tt.Fatalf("dial err: %+v", err)
+		}
+		defer client.Close()
+		server, ok := <-accepted
+		if ok != true {
+			tt.Fatalf("accept failed")
+		}
+		defer server.Close()
+
+		name, err := GetBindToDevice(client)
+		if err != nil {
+			tt.Fatalf("bindtodevice get err: %+v", err)
+		}
+		if name != lo.Name {
+			tt.Errorf("bound to %s, actual:%q", lo.Name, name)
+		}
+		ifindex, err := GetBindToIfindex(client)
+		if err != nil {
+			tt.Fatalf("bindtoifindex get err: %+v", err)
+		}
+		if ifindex != lo.Index {
+			tt.Errorf("bound to index %d, actual:%d", lo.Index, ifindex)
+		}
+	})
+	t.Run("ifindex", func(tt *testing.T) {
+		cfg := Config{BindToIfindex: lo.Index}
+		listener, err := Listen(context.TODO(), "tcp", "127.0.0.1:0", Config{})
+		if err != nil {
+			tt.Fatalf("listen err: %+v", err)
+		}
+		defer listener.Close()
+
+		go func() {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}()
+
+		client, err := Dial(context.TODO(), "tcp", listener.Addr().String(), cfg)
+		if err != nil {
+			if IsPrivilegeError(err) {
+				tt.Skipf("not privileged: %+v", err)
+			}
+			tt.Fatalf("dial err: %+v", err)
+		}
+		defer client.Close()
+
+		name, err := GetBindToDevice(client)
+		if err != nil {
+			tt.Fatalf("bindtodevice get err: %+v", err)
+		}
+		if name != lo.Name {
+			tt.Errorf("bound to %s, actual:%q", lo.Name, name)
+		}
+	})
+	t.Run("unbind", func(tt *testing.T) {
+		client, server := setupConnPair(tt)
+		defer client.Close()
+		defer server.Close()
+
+		if err := BindToDevice(client, lo.Name); err != nil {
+			if IsPrivilegeError(err) {
+				tt.Skipf("not privileged: %+v", err)
+			}
+			tt.Fatalf("bindtodevice set err: %+v", err)
+		}
+		if err := BindToDevice(client, ""); err != nil {
+			if IsPrivilegeError(err) {
+				tt.Skipf("not privileged: %+v", err)
+			}
+			tt.Fatalf("bindtodevice unset err: %+v", err)
+		}
+		name, err := GetBindToDevice(client)
+		if err != nil {
+			tt.Fatalf("bindtodevice get err: %+v", err)
+		}
+		if name != "" {
+			tt.Errorf("must be unbound, actual:%q", name)
+		}
+	})
+}
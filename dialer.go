@@ -0,0 +1,183 @@
+package tcpoption
+
+import (
+	"context"
+	"errors"
+	"net"
+	"syscall"
+)
+
+// Dialer returns net.Dialer applying cfg to the socket before connect,
+// options such as SO_MARK or TCP_MAXSEG only affect the SYN when set at this point.
+func Dialer(cfg Config) *net.Dialer {
+	return &net.Dialer{
+		Control: Control(cfg),
+	}
+}
+
+// ListenConfig returns net.ListenConfig applying cfg to the socket before bind and listen
+func ListenConfig(cfg Config) *net.ListenConfig {
+	return &net.ListenConfig{
+		Control: Control(cfg),
+	}
+}
+
+// Dial connects with Dialer(cfg) then applies the remaining options with Set
+func Dial(ctx context.Context, network, address string, cfg Config) (net.Conn, error) {
+	conn, err := Dialer(cfg).DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	if err := Set(conn, cfg); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// Listen listens with ListenConfig(cfg), returned listener is *net.TCPListener
+// so listener functions (SetFastOpenKey, SetSaveSYN, ...) keep working.
+// accepted conns inherit socket options from the listener, apply Set for the rest.
+func Listen(ctx context.Context, network, address string, cfg Config) (net.Listener, error) {
+	return ListenConfig(cfg).Listen(ctx, network, address)
+}
+
+// Control is net.Dialer/net.ListenConfig Control calling SetPreConnectFd
+func Control(cfg Config) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		var fdErr error
+		if err := c.Control(func(fd uintptr) {
+			fdErr = SetPreConnectFd(int(fd), cfg)
+		}); err != nil {
+			return err
+		}
+		return fdErr
+	}
+}
+
+// SetPreConnectFd applies the options of cfg that must be set before connect or bind
+func SetPreConnectFd(fd int, cfg Config) error {
+	if cfg.EnableReuseAddr {
+		if err := setsockoptReuseAddr(fd, 1); err != nil {
+			return err
+		}
+	}
+	if cfg.EnableReusePort {
+		if err := setsockoptReusePort(fd, 1); err != nil {
+			return err
+		}
+	}
+	if cfg.V6Only != V6OnlyDefault {
+		if err := SetV6OnlyFd(fd, cfg.V6Only == V6OnlyEnable); err != nil {
+			return err
+		}
+	}
+	if cfg.Transparent {
+		if err := SetTransparentFd(fd, true); err != nil {
+			return err
+		}
+	}
+	if cfg.FreeBind {
+		if err := SetFreeBindFd(fd, true); err != nil {
+			return err
+		}
+	}
+	for _, k := range cfg.MD5Keys {
+		if err := SetMD5KeyFd(fd, k.Peer, k.Key, k.Ifindex); err != nil {
+			return err
+		}
+	}
+	for i, k := range cfg.AOKeys {
+		// kernel would pick the last key added on connect, AOKeys[0] is selected explicitly
+		if err := AddAOKeyFd(fd, k, i == 0, i == 0); err != nil {
+			return err
+		}
+	}
+	if cfg.BindToDevice != "" {
+		if err := setsockoptBindToDevice(fd, cfg.BindToDevice); err != nil {
+			return err
+		}
+	}
+	if 0 < cfg.BindToIfindex {
+		if err := setsockoptBindToIfindex(fd, cfg.BindToIfindex); err != nil {
+			return err
+		}
+	}
+	if cfg.BindAddressNoPort {
+		if err := setsockoptBindAddressNoPort(fd, 1); err != nil {
+			if errors.Is(err, syscall.ENOPROTOOPT) != true {
+				return err
+			}
+			// kernel < 4.2, port is allocated at bind
+		}
+	}
+	if cfg.LocalPortRange.IsZero() != true {
+		if err := setsockoptLocalPortRange(fd, cfg.LocalPortRange.value()); err != nil {
+			if errors.Is(err, syscall.ENOPROTOOPT) != true {
+				return err
+			}
+			// kernel < 6.3, net.ipv4.ip_local_port_range applies
+		}
+	}
+	if 0 < cfg.Mark {
+		if err := setsockoptMark(fd, cfg.Mark); err != nil {
+			return err
+		}
+	}
+	if 0 < cfg.Priority {
+		if err := setsockoptPriority(fd, cfg.Priority); err != nil {
+			return err
+		}
+	}
+	if 0 < cfg.DSCP {
+		if err := SetDSCPFd(fd, cfg.DSCP); err != nil {
+			return err
+		}
+	}
+	if cfg.MTUDiscover != PMTUDiscDefault {
+		if err := SetMTUDiscoverFd(fd, cfg.MTUDiscover); err != nil {
+			return err
+		}
+	}
+	if 0 < cfg.TTL {
+		if err := setTTLFd(fd, cfg.TTL); err != nil {
+			return err
+		}
+	}
+	if 0 < cfg.MinTTL {
+		if err := setMinTTLFd(fd, cfg.MinTTL); err != nil {
+			return err
+		}
+	}
+	if cfg.GTSM {
+		if err := GTSMFd(fd); err != nil {
+			return err
+		}
+	}
+	if 0 < cfg.MaxSeg {
+		if err := setsockoptMaxSeg(fd, cfg.MaxSeg); err != nil {
+			return err
+		}
+	}
+	if 0 < cfg.WindowClamp {
+		if err := setsockoptWindowClamp(fd, cfg.WindowClamp); err != nil {
+			return err
+		}
+	}
+	if 0 < cfg.Retransmit.SynRetries {
+		if err := setsockoptSynCount(fd, cfg.Retransmit.SynRetries); err != nil {
+			return err
+		}
+	}
+	if 0 < cfg.FastOpen {
+		if err := setsockoptFastOpen(fd, cfg.FastOpen); err != nil {
+			return err
+		}
+	}
+	if 0 < cfg.FastOpenConnect {
+		if err := setsockoptFastOpenConnect(fd, cfg.FastOpenConnect); err != nil {
+			return err
+		}
+	}
+	return nil
+}
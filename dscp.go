@@ -0,0 +1,242 @@
+package tcpoption
+
+import (
+	"net"
+	"strconv"
+	"syscall"
+)
+
+type DSCP uint8
+
+// RFC 2474, RFC 2597, RFC 3246
+const (
+	DSCP_CS0  DSCP = 0
+	DSCP_CS1  DSCP = 8
+	DSCP_AF11 DSCP = 10
+	DSCP_AF12 DSCP = 12
+	DSCP_AF13 DSCP = 14
+	DSCP_CS2  DSCP = 16
+	DSCP_AF21 DSCP = 18
+	DSCP_AF22 DSCP = 20
+	DSCP_AF23 DSCP = 22
+	DSCP_CS3  DSCP = 24
+	DSCP_AF31 DSCP = 26
+	DSCP_AF32 DSCP = 28
+	DSCP_AF33 DSCP = 30
+	DSCP_CS4  DSCP = 32
+	DSCP_AF41 DSCP = 34
+	DSCP_AF42 DSCP = 36
+	DSCP_AF43 DSCP = 38
+	DSCP_CS5  DSCP = 40
+	DSCP_EF   DSCP = 46
+	DSCP_CS6  DSCP = 48
+	DSCP_CS7  DSCP = 56
+)
+
+const (
+	ecnMask int = 0x03
+)
+
+var dscpNames = map[DSCP]string{
+	DSCP_CS0:  "CS0",
+	DSCP_CS1:  "CS1",
+	DSCP_AF11: "AF11",
+	DSCP_AF12: "AF12",
+	DSCP_AF13: "AF13",
+	DSCP_CS2:  "CS2",
+	DSCP_AF21: "AF21",
+	DSCP_AF22: "AF22",
+	DSCP_AF23: "AF23",
+	DSCP_CS3:  "CS3",
+	DSCP_AF31: "AF31",
+	DSCP_AF32: "AF32",
+	DSCP_AF33: "AF33",
+	DSCP_CS4:  "CS4",
+	DSCP_AF41: "AF41",
+	DSCP_AF42: "AF42",
+	DSCP_AF43: "AF43",
+	DSCP_CS5:  "CS5",
+	DSCP_EF:   "EF",
+	DSCP_CS6:  "CS6",
+	DSCP_CS7:  "CS7",
+}
+
+func (d DSCP) String() string {
+	if name, ok := dscpNames[d]; ok {
+		return name
+	}
+	return "DSCP(" + strconv.Itoa(int(d)) + ")"
+}
+
+// TOS returns IP_TOS/IPV6_TCLASS byte keeping ECN bits of current
+func (d DSCP) TOS(current int) int {
+	return (int(d&0x3f) << 2) | (current & ecnMask)
+}
+
+// SetDSCP marks conn with dscp, IP_TOS or IPV6_TCLASS is chosen by socket family.
+// ECN bits already set on the socket are preserved.
+func SetDSCP(conn net.Conn, dscp DSCP) error {
+	if c, ok := conn.(*net.TCPConn); ok {
+		return getFd(c, func(fd int) error {
+			return SetDSCPFd(fd, dscp)
+		})
+	}
+	return nil
+}
+
+func SetDSCPFd(fd int, dscp DSCP) error {
+	ipv4, ipv6, err := tosFamily(fd)
+	if err != nil {
+		return err
+	}
+	if ipv6 {
+		current, err := getsockoptTrafficClass(fd)
+		if err != nil {
+			return err
+		}
+		if err := setsockoptTrafficClass(fd, dscp.TOS(current)); err != nil {
+			return err
+		}
+	}
+	if ipv4 {
+		current, err := getsockoptTOS(fd)
+		if err != nil {
+			return err
+		}
+		if err := setsockoptTOS(fd, dscp.TOS(current)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func GetDSCP(conn net.Conn) (DSCP, error) {
+	if c, ok := conn.(*net.TCPConn); ok {
+		dscp := DSCP(0)
+		if err := getFd(c, func(fd int) error {
+			v, err := GetDSCPFd(fd)
+			if err != nil {
+				return err
+			}
+			dscp = v
+			return nil
+		}); err != nil {
+			return 0, err
+		}
+		return dscp, nil
+	}
+	return 0, nil
+}
+
+func GetDSCPFd(fd int) (DSCP, error) {
+	ipv4, _, err := tosFamily(fd)
+	if err != nil {
+		return 0, err
+	}
+	tos := 0
+	if ipv4 {
+		tos, err = getsockoptTOS(fd)
+	} else {
+		tos, err = getsockoptTrafficClass(fd)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return DSCP(tos >> 2), nil
+}
+
+func SetTOS(conn net.Conn, tos int) error {
+	if c, ok := conn.(*net.TCPConn); ok {
+		return getFd(c, func(fd int) error {
+			return setsockoptTOS(fd, tos)
+		})
+	}
+	return nil
+}
+
+func SetTOSFd(fd int, tos int) error {
+	return setsockoptTOS(fd, tos)
+}
+
+func GetTOS(conn net.Conn) (int, error) {
+	if c, ok := conn.(*net.TCPConn); ok {
+		tos := 0
+		if err := getFd(c, func(fd int) error {
+			v, err := getsockoptTOS(fd)
+			if err != nil {
+				return err
+			}
+			tos = v
+			return nil
+		}); err != nil {
+			return 0, err
+		}
+		return tos, nil
+	}
+	return 0, nil
+}
+
+func SetTrafficClass(conn net.Conn, tclass int) error {
+	if c, ok := conn.(*net.TCPConn); ok {
+		return getFd(c, func(fd int) error {
+			return setsockoptTrafficClass(fd, tclass)
+		})
+	}
+	return nil
+}
+
+func SetTrafficClassFd(fd int, tclass int) error {
+	return setsockoptTrafficClass(fd, tclass)
+}
+
+func GetTrafficClass(conn net.Conn) (int, error) {
+	if c, ok := conn.(*net.TCPConn); ok {
+		tclass := 0
+		if err := getFd(c, func(fd int) error {
+			v, err := getsockoptTrafficClass(fd)
+			if err != nil {
+				return err
+			}
+			tclass = v
+			return nil
+		}); err != nil {
+			return 0, err
+		}
+		return tclass, nil
+	}
+	return 0, nil
+}
+
+// tosFamily reports which of IP_TOS / IPV6_TCLASS applies to fd.
+// AF_INET6 socket carries IPv4 packets when the peer is v4-mapped,
+// unconnected dual-stack socket may carry both.
+func tosFamily(fd int) (bool, bool, error) {
+	local, err := syscall.Getsockname(fd)
+	if err != nil {
+		return false, false, err
+	}
+	local6, ok := local.(*syscall.SockaddrInet6)
+	if ok != true {
+		return true, false, nil
+	}
+	if isV4Mapped(local6.Addr) {
+		return true, false, nil
+	}
+	peer, err := syscall.Getpeername(fd)
+	if err != nil {
+		if err == syscall.ENOTCONN {
+			dual := net.IP(local6.Addr[:]).IsUnspecified()
+			return dual, true, nil
+		}
+		return false, false, err
+	}
+	if peer6, ok := peer.(*syscall.SockaddrInet6); ok && isV4Mapped(peer6.Addr) {
+		return true, false, nil
+	}
+	return false, true, nil
+}
+
+func isV4Mapped(addr [16]byte) bool {
+	ip := net.IP(addr[:])
+	return ip.To4() != nil
+}
@@ -0,0 +1,131 @@
+package tcpoption
+
+import (
+	"net"
+	"testing"
+)
+
+func dialPair(t *testing.T, network, listenAddr, dialNetwork string) (net.Conn, net.Conn) {
+	listener, err := net.Listen(network, listenAddr)
+	if err != nil {
+		t.Skipf("listen %s %s err: %+v", network, listenAddr, err)
+	}
+	defer listener.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			close(accepted)
+			return
+		}
+		accepted <- conn
+	}()
+
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	host := "127.0.0.1"
+	if dialNetwork == "tcp6" {
+		host = "::1"
+	}
+	client, err := net.Dial(dialNetwork, net.JoinHostPort(host, port))
+	if err != nil {
+		t.Fatalf("client open err: %+v", err)
+	}
+	server, ok := <-accepted
+	if ok != true {
+		t.Fatalf("accept failed")
+	}
+	return client, server
+}
+
+func TestSetDSCP(t *testing.T) {
+	t.Run("ipv4", func(tt *testing.T) {
+		client, server := dialPair(tt, "tcp4", "127.0.0.1:0", "tcp4")
+		defer client.Close()
+		defer server.Close()
+
+		if err := SetDSCP(client, DSCP_EF); err != nil {
+			tt.Fatalf("dscp set err: %+v", err)
+		}
+		tos, err := GetTOS(client)
+		if err != nil {
+			tt.Fatalf("tos get err: %+v", err)
+		}
+		if tos != 0xb8 {
+			tt.Errorf("EF tos 0xb8, actual:%#x", tos)
+		}
+		dscp, err := GetDSCP(client)
+		if err != nil {
+			tt.Fatalf("dscp get err: %+v", err)
+		}
+		if dscp != DSCP_EF {
+			tt.Errorf("expect EF, actual:%s", dscp)
+		}
+	})
+	t.Run("ipv6", func(tt *testing.T) {
+		client, server := dialPair(tt, "tcp6", "[::1]:0", "tcp6")
+		defer client.Close()
+		defer server.Close()
+
+		if err := SetDSCP(client, DSCP_AF41); err != nil {
+			tt.Fatalf("dscp set err: %+v", err)
+		}
+		tclass, err := GetTrafficClass(client)
+		if err != nil {
+			tt.Fatalf("tclass get err: %+v", err)
+		}
+		if tclass != int(DSCP_AF41)<<2 {
+			tt.Errorf("AF41 tclass %#x, actual:%#x", int(DSCP_AF41)<<2, tclass)
+		}
+		dscp, err := GetDSCP(client)
+		if err != nil {
+			tt.Fatalf("dscp get err: %+v", err)
+		}
+		if dscp != DSCP_AF41 {
+			tt.Errorf("expect AF41, actual:%s", dscp)
+		}
+	})
+	t.Run("v4mapped", func(tt *testing.T) {
+		client, server := dialPair(tt, "tcp", "[::]:0", "tcp4")
+		defer client.Close()
+		defer server.Close()
+
+		if err := SetDSCP(server, DSCP_CS3); err != nil {
+			tt.Fatalf("dscp set err: %+v", err)
+		}
+		tos, err := GetTOS(server)
+		if err != nil {
+			tt.Fatalf("tos get err: %+v", err)
+		}
+		if tos != int(DSCP_CS3)<<2 {
+			tt.Errorf("v4-mapped conn must use IP_TOS %#x, actual:%#x", int(DSCP_CS3)<<2, tos)
+		}
+		dscp, err := GetDSCP(server)
+		if err != nil {
+			tt.Fatalf("dscp get err: %+v", err)
+		}
+		if dscp != DSCP_CS3 {
+			tt.Errorf("expect CS3, actual:%s", dscp)
+		}
+	})
+	t.Run("keep_ecn", func(tt *testing.T) {
+		client, server := dialPair(tt, "tcp4", "127.0.0.1:0", "tcp4")
+		defer client.Close()
+		defer server.Close()
+
+		if err := SetTOS(client, 0x02); err != nil { // ECT(0)
+			tt.Fatalf("tos set err: %+v", err)
+		}
+		before, _ := GetTOS(client)
+		if err := SetDSCP(client, DSCP_AF11); err != nil {
+			tt.Fatalf("dscp set err: %+v", err)
+		}
+		after, err := GetTOS(client)
+		if err != nil {
+			tt.Fatalf("tos get err: %+v", err)
+		}
+		if after != (int(DSCP_AF11)<<2)|(before&0x03) {
+			tt.Errorf("ecn bits must be kept before:%#x after:%#x", before, after)
+		}
+	})
+}
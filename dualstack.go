@@ -0,0 +1,245 @@
+package tcpoption
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+)
+
+type V6OnlyMode uint8
+
+const (
+	V6OnlyDefault V6OnlyMode = iota // keep Go default (v6only off for "tcp" on [::], net.ipv6.bindv6only otherwise)
+	V6OnlyEnable
+	V6OnlyDisable
+)
+
+// SetV6OnlyFd sets IPV6_V6ONLY, must be called before bind. ignored on AF_INET sockets.
+func SetV6OnlyFd(fd int, enable bool) error {
+	ipv6, err := isIPv6Socket(fd)
+	if err != nil {
+		return err
+	}
+	if ipv6 != true {
+		return nil
+	}
+	return setsockoptV6Only(fd, IntBool(enable))
+}
+
+func GetV6OnlyFd(fd int) (bool, error) {
+	ipv6, err := isIPv6Socket(fd)
+	if err != nil {
+		return false, err
+	}
+	if ipv6 != true {
+		return false, nil
+	}
+	v, err := getsockoptV6Only(fd)
+	if err != nil {
+		return false, err
+	}
+	return v != 0, nil
+}
+
+func GetV6Only(conn net.Conn) (bool, error) {
+	if c, ok := conn.(*net.TCPConn); ok {
+		enable := false
+		if err := getFd(c, func(fd int) error {
+			v, err := GetV6OnlyFd(fd)
+			if err != nil {
+				return err
+			}
+			enable = v
+			return nil
+		}); err != nil {
+			return false, err
+		}
+		return enable, nil
+	}
+	return false, nil
+}
+
+func GetListenerV6Only(listener net.Listener) (bool, error) {
+	if l, ok := listener.(*net.TCPListener); ok {
+		enable := false
+		if err := getFd(l, func(fd int) error {
+			v, err := GetV6OnlyFd(fd)
+			if err != nil {
+				return err
+			}
+			enable = v
+			return nil
+		}); err != nil {
+			return false, err
+		}
+		return enable, nil
+	}
+	return false, nil
+}
+
+type Family uint8
+
+const (
+	FamilyUnknown Family = iota
+	FamilyIPv4
+	FamilyIPv6
+)
+
+func (f Family) String() string {
+	switch f {
+	case FamilyIPv4:
+		return "ipv4"
+	case FamilyIPv6:
+		return "ipv6"
+	}
+	return "unknown"
+}
+
+// ConnFamily returns the family of conn peer, v4-mapped address on dual-stack socket is ipv4
+func ConnFamily(conn net.Conn) Family {
+	addr, ok := conn.RemoteAddr().(*net.TCPAddr)
+	if ok != true {
+		return FamilyUnknown
+	}
+	if addr.IP.To4() != nil {
+		return FamilyIPv4
+	}
+	if addr.IP.To16() != nil {
+		return FamilyIPv6
+	}
+	return FamilyUnknown
+}
+
+type DualStackMode uint8
+
+const (
+	DualStackSingle DualStackMode = iota // one [::] socket with IPV6_V6ONLY off
+	DualStackPair                        // 0.0.0.0 and [::] with IPV6_V6ONLY on, same port
+)
+
+type acceptResult struct {
+	conn net.Conn
+	err  error
+}
+
+// DualStackListener accepts IPv4 and IPv6 regardless of net.ipv6.bindv6only,
+// either from one dual-stack socket or from a v4/v6 pair merged into one Accept.
+type DualStackListener struct {
+	listeners []net.Listener
+	accepted  chan acceptResult
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// ListenDualStack listens on address ":port" (port 0 picks one port for both sockets),
+// cfg is applied to every socket, cfg.V6Only is decided by mode.
+func ListenDualStack(ctx context.Context, address string, mode DualStackMode, cfg Config) (*DualStackListener, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	if host != "" && host != "::" && host != "0.0.0.0" {
+		return nil, fmt.Errorf("dual-stack listener requires wildcard address: %s", address)
+	}
+
+	l := &DualStackListener{
+		accepted: make(chan acceptResult),
+		done:     make(chan struct{}),
+	}
+	switch mode {
+	case DualStackPair:
+		cfg4 := cfg
+		cfg4.V6Only = V6OnlyDefault
+		ln4, err := Listen(ctx, "tcp4", net.JoinHostPort("0.0.0.0", port), cfg4)
+		if err != nil {
+			return nil, err
+		}
+		port = strconv.Itoa(ln4.Addr().(*net.TCPAddr).Port)
+
+		cfg6 := cfg
+		cfg6.V6Only = V6OnlyEnable
+		ln6, err := Listen(ctx, "tcp6", net.JoinHostPort("::", port), cfg6)
+		if err != nil {
+			ln4.Close()
+			return nil, err
+		}
+		l.listeners = []net.Listener{ln4, ln6}
+	default:
+		cfg.V6Only = V6OnlyDisable
+		ln, err := Listen(ctx, "tcp6", net.JoinHostPort("::", port), cfg)
+		if err != nil {
+			return nil, err
+		}
+		l.listeners = []net.Listener{ln}
+	}
+
+	for _, ln := range l.listeners {
+		l.wg.Add(1)
+		go l.run(ln)
+	}
+	return l, nil
+}
+
+func (l *DualStackListener) run(ln net.Listener) {
+	defer l.wg.Done()
+
+	for {
+		conn, err := ln.Accept()
+		select {
+		case l.accepted <- acceptResult{conn, err}:
+		case <-l.done:
+			if conn != nil {
+				conn.Close()
+			}
+			return
+		}
+		if err != nil && errors.Is(err, net.ErrClosed) {
+			return
+		}
+	}
+}
+
+func (l *DualStackListener) Accept() (net.Conn, error) {
+	select {
+	case r := <-l.accepted:
+		return r.conn, r.err
+	case <-l.done:
+		return nil, &net.OpError{Op: "accept", Net: "tcp", Addr: l.Addr(), Err: net.ErrClosed}
+	}
+}
+
+// AcceptFamily accepts and reports the family of the peer
+func (l *DualStackListener) AcceptFamily() (net.Conn, Family, error) {
+	conn, err := l.Accept()
+	if err != nil {
+		return nil, FamilyUnknown, err
+	}
+	return conn, ConnFamily(conn), nil
+}
+
+func (l *DualStackListener) Close() error {
+	var closeErr error
+	l.closeOnce.Do(func() {
+		close(l.done)
+		for _, ln := range l.listeners {
+			if err := ln.Close(); err != nil && closeErr == nil {
+				closeErr = err
+			}
+		}
+		l.wg.Wait()
+	})
+	return closeErr
+}
+
+// Addr returns the address of the first socket, every socket shares the same port
+func (l *DualStackListener) Addr() net.Addr {
+	return l.listeners[0].Addr()
+}
+
+func (l *DualStackListener) Listeners() []net.Listener {
+	return l.listeners
+}
@@ -0,0 +1,88 @@
+package tcpoption
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+)
+
+func TestV6Only(t *testing.T) {
+	for _, tc := range []struct {
+		mode   V6OnlyMode
+		expect bool
+	}{
+		{V6OnlyEnable, true},
+		{V6OnlyDisable, false},
+	} {
+		listener, err := Listen(context.TODO(), "tcp6", "[::]:0", Config{V6Only: tc.mode})
+		if err != nil {
+			t.Skipf("ipv6 listen err: %+v", err)
+		}
+		v, err := GetListenerV6Only(listener)
+		listener.Close()
+		if err != nil {
+			t.Fatalf("v6only get err: %+v", err)
+		}
+		if v != tc.expect {
+			t.Errorf("mode %d expect v6only=%v, actual:%v", tc.mode, tc.expect, v)
+		}
+	}
+
+	listener, err := Listen(context.TODO(), "tcp4", "127.0.0.1:0", Config{V6Only: V6OnlyEnable})
+	if err != nil {
+		t.Fatalf("ipv4 socket must ignore v6only: %+v", err)
+	}
+	listener.Close()
+}
+
+func TestDualStackListener(t *testing.T) {
+	for _, mode := range []DualStackMode{DualStackSingle, DualStackPair} {
+		listener, err := ListenDualStack(context.TODO(), ":0", mode, Config{EnableNoDelay: true})
+		if err != nil {
+			t.Skipf("dual-stack listen err: %+v", err)
+		}
+
+		port := strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)
+		expectListeners := 1
+		if mode == DualStackPair {
+			expectListeners = 2
+		}
+		if len(listener.Listeners()) != expectListeners {
+			t.Errorf("mode %d expect %d sockets, actual:%d", mode, expectListeners, len(listener.Listeners()))
+		}
+
+		for _, tc := range []struct {
+			network string
+			host    string
+			family  Family
+		}{
+			{"tcp4", "127.0.0.1", FamilyIPv4},
+			{"tcp6", "::1", FamilyIPv6},
+		} {
+			client, err := net.Dial(tc.network, net.JoinHostPort(tc.host, port))
+			if err != nil {
+				t.Fatalf("mode %d dial %s err: %+v", mode, tc.network, err)
+			}
+			server, family, err := listener.AcceptFamily()
+			if err != nil {
+				t.Fatalf("accept err: %+v", err)
+			}
+			if family != tc.family {
+				t.Errorf("mode %d expect %s, actual:%s", mode, tc.family, family)
+			}
+			if _, ok := server.(*net.TCPConn); ok != true {
+				t.Errorf("accepted conn must be *net.TCPConn: %T", server)
+			}
+			client.Close()
+			server.Close()
+		}
+
+		if err := listener.Close(); err != nil {
+			t.Errorf("close err: %+v", err)
+		}
+		if _, err := listener.Accept(); err == nil {
+			t.Errorf("accept after close must fail")
+		}
+	}
+}
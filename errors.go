@@ -0,0 +1,38 @@
+package tcpoption
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+)
+
+// PrivilegeError is returned when the kernel refused an option for lack of capability
+type PrivilegeError struct {
+	Option     string
+	Capability string
+	Err        error
+}
+
+func (e *PrivilegeError) Error() string {
+	return fmt.Sprintf("%s requires %s: %v", e.Option, e.Capability, e.Err)
+}
+
+func (e *PrivilegeError) Unwrap() error {
+	return e.Err
+}
+
+func IsPrivilegeError(err error) bool {
+	var e *PrivilegeError
+	return errors.As(err, &e)
+}
+
+func privilegeError(option, capability string, err error) error {
+	if errors.Is(err, syscall.EPERM) || errors.Is(err, syscall.EACCES) {
+		return &PrivilegeError{
+			Option:     option,
+			Capability: capability,
+			Err:        err,
+		}
+	}
+	return err
+}
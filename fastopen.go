@@ -0,0 +1,271 @@
+package tcpoption
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	FastOpenKeyLength int = 16
+
+	DefaultKeyRotateInterval time.Duration = 1 * time.Hour
+)
+
+type FastOpenKey [FastOpenKeyLength]byte
+
+// SetFastOpenKey sets the TFO cookie keys, backup is still accepted for validation.
+// zero backup installs primary only.
+func SetFastOpenKey(listener net.Listener, primary, backup FastOpenKey) error {
+	if l, ok := listener.(*net.TCPListener); ok {
+		return getFd(l, func(fd int) error {
+			return SetFastOpenKeyFd(fd, primary, backup)
+		})
+	}
+	return nil
+}
+
+func SetFastOpenKeyFd(fd int, primary, backup FastOpenKey) error {
+	if backup == (FastOpenKey{}) {
+		return setsockoptFastOpenKey(fd, primary[:])
+	}
+	keys := make([]byte, 0, 2*FastOpenKeyLength)
+	keys = append(keys, primary[:]...)
+	keys = append(keys, backup[:]...)
+	return setsockoptFastOpenKey(fd, keys)
+}
+
+func GetFastOpenKey(listener net.Listener) (FastOpenKey, FastOpenKey, error) {
+	primary, backup := FastOpenKey{}, FastOpenKey{}
+	if l, ok := listener.(*net.TCPListener); ok {
+		if err := getFd(l, func(fd int) error {
+			p, b, err := GetFastOpenKeyFd(fd)
+			if err != nil {
+				return err
+			}
+			primary, backup = p, b
+			return nil
+		}); err != nil {
+			return primary, backup, err
+		}
+	}
+	return primary, backup, nil
+}
+
+func GetFastOpenKeyFd(fd int) (FastOpenKey, FastOpenKey, error) {
+	primary, backup := FastOpenKey{}, FastOpenKey{}
+	keys, err := getsockoptFastOpenKey(fd)
+	if err != nil {
+		return primary, backup, err
+	}
+	if FastOpenKeyLength <= len(keys) {
+		copy(primary[:], keys[:FastOpenKeyLength])
+	}
+	if 2*FastOpenKeyLength <= len(keys) {
+		copy(backup[:], keys[FastOpenKeyLength:])
+	}
+	return primary, backup, nil
+}
+
+// SetFastOpenNoCookie accepts data in SYN without a cookie, only for trusted clients.
+func SetFastOpenNoCookie(listener net.Listener, enable bool) error {
+	if l, ok := listener.(*net.TCPListener); ok {
+		return getFd(l, func(fd int) error {
+			return setsockoptFastOpenNoCookie(fd, IntBool(enable))
+		})
+	}
+	return nil
+}
+
+func SetFastOpenNoCookieFd(fd int, enable bool) error {
+	return setsockoptFastOpenNoCookie(fd, IntBool(enable))
+}
+
+// FastOpenKeySource returns the key of epoch, every host must return the same key for the same epoch.
+type FastOpenKeySource func(epoch int64) (FastOpenKey, error)
+
+// HMACKeySource derives keys from a secret shared between hosts behind the same VIP.
+func HMACKeySource(secret []byte) FastOpenKeySource {
+	return func(epoch int64) (FastOpenKey, error) {
+		buf := make([]byte, 8)
+		binary.BigEndian.PutUint64(buf, uint64(epoch))
+
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(buf)
+		key := FastOpenKey{}
+		copy(key[:], mac.Sum(nil))
+		return key, nil
+	}
+}
+
+// KeyRotator installs source(epoch) as primary and source(epoch-1) as backup,
+// epoch is derived from wall clock so hosts rotate at the same time.
+type KeyRotator struct {
+	listener net.Listener
+	source   FastOpenKeySource
+	interval time.Duration
+	onError  func(error)
+
+	mutex   sync.Mutex
+	done    chan struct{}
+	stopped bool
+	wg      sync.WaitGroup
+}
+
+// interval <= 0 uses DefaultKeyRotateInterval
+func NewKeyRotator(listener net.Listener, source FastOpenKeySource, interval time.Duration, onError func(error)) *KeyRotator {
+	if interval <= 0 {
+		interval = DefaultKeyRotateInterval
+	}
+	return &KeyRotator{
+		listener: listener,
+		source:   source,
+		interval: interval,
+		onError:  onError,
+		done:     make(chan struct{}),
+	}
+}
+
+func (r *KeyRotator) Epoch(t time.Time) int64 {
+	return t.UnixNano() / int64(r.interval)
+}
+
+func (r *KeyRotator) Rotate(t time.Time) error {
+	epoch := r.Epoch(t)
+	primary, err := r.source(epoch)
+	if err != nil {
+		return err
+	}
+	backup, err := r.source(epoch - 1)
+	if err != nil {
+		return err
+	}
+	return SetFastOpenKey(r.listener, primary, backup)
+}
+
+// Start rotates immediately, then at every epoch boundary until Stop
+func (r *KeyRotator) Start() error {
+	if err := r.Rotate(time.Now()); err != nil {
+		return err
+	}
+	r.wg.Add(1)
+	go r.run()
+	return nil
+}
+
+func (r *KeyRotator) Stop() {
+	r.mutex.Lock()
+	if r.stopped != true {
+		r.stopped = true
+		close(r.done)
+	}
+	r.mutex.Unlock()
+
+	r.wg.Wait()
+}
+
+func (r *KeyRotator) run() {
+	defer r.wg.Done()
+
+	for {
+		now := time.Now()
+		next := time.Unix(0, (r.Epoch(now)+1)*int64(r.interval))
+		tm := time.NewTimer(next.Sub(now))
+		select {
+		case <-r.done:
+			tm.Stop()
+			return
+		case t := <-tm.C:
+			if err := r.Rotate(t); err != nil {
+				if r.onError != nil {
+					r.onError(err)
+				}
+			}
+		}
+	}
+}
+
+type FastOpenResult struct {
+	SentInSYN     bool // payload was carried in the SYN
+	Accepted      bool // SYN-ACK acknowledged the payload
+	CookieMissing bool // no cookie was cached, SYN requested one
+	Fallback      bool // payload went out after a normal handshake
+}
+
+// DialFastOpen connects to address sending first in the SYN when a cookie is cached.
+// TCP_FASTOPEN_CONNECT is used, sendto(MSG_FASTOPEN) on kernels without it.
+func DialFastOpen(ctx context.Context, network, address string, first []byte, cfg Config) (net.Conn, FastOpenResult, error) {
+	result := FastOpenResult{}
+	sent := 0
+	d := &net.Dialer{
+		Control: func(network, address string, c syscall.RawConn) error {
+			var fdErr error
+			if err := c.Control(func(fd uintptr) {
+				n, err := connectFastOpen(int(fd), address, first)
+				if err != nil {
+					fdErr = err
+					return
+				}
+				sent = n
+			}); err != nil {
+				return err
+			}
+			return fdErr
+		},
+	}
+	conn, err := d.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, result, err
+	}
+	if err := Set(conn, cfg); err != nil {
+		conn.Close()
+		return nil, result, err
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetWriteDeadline(deadline)
+		defer conn.SetWriteDeadline(time.Time{})
+	}
+	if sent < len(first) {
+		if _, err := conn.Write(first[sent:]); err != nil {
+			conn.Close()
+			return nil, result, err
+		}
+	}
+
+	c, ok := conn.(*net.TCPConn)
+	if ok != true {
+		return conn, result, nil
+	}
+	raw, err := c.SyscallConn()
+	if err != nil {
+		conn.Close()
+		return nil, result, err
+	}
+	var fdErr error
+	if err := raw.Write(func(fd uintptr) bool {
+		r, connected, err := getsockoptFastOpenResult(int(fd))
+		if err != nil {
+			fdErr = err
+			return true
+		}
+		result = r
+		return connected
+	}); err != nil {
+		conn.Close()
+		return nil, result, err
+	}
+	if fdErr != nil {
+		conn.Close()
+		return nil, result, fdErr
+	}
+	if 0 < sent {
+		result.SentInSYN = true
+	}
+	return conn, result, nil
+}
@@ -0,0 +1,214 @@
+package tcpoption
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestSetFastOpenKey(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen err: %+v", err)
+	}
+	defer listener.Close()
+
+	primary := FastOpenKey{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	backup := FastOpenKey{16, 15, 14, 13, 12, 11, 10, 9, 8, 7, 6, 5, 4, 3, 2, 1}
+
+	t.Run("primary", func(tt *testing.T) {
+		if err := SetFastOpenKey(listener, primary, FastOpenKey{}); err != nil {
+			tt.Fatalf("fastopen key set err: %+v", err)
+		}
+		p, b, err := GetFastOpenKey(listener)
+		if err != nil {
+			tt.Fatalf("fastopen key get err: %+v", err)
+		}
+		if p != primary {
+			tt.Errorf("primary key: %v", p)
+		}
+		if b != (FastOpenKey{}) {
+			tt.Errorf("no backup key: %v", b)
+		}
+	})
+	t.Run("primary_backup", func(tt *testing.T) {
+		if err := SetFastOpenKey(listener, primary, backup); err != nil {
+			tt.Fatalf("fastopen key set err: %+v", err)
+		}
+		p, b, err := GetFastOpenKey(listener)
+		if err != nil {
+			tt.Fatalf("fastopen key get err: %+v", err)
+		}
+		if p != primary {
+			tt.Errorf("primary key: %v", p)
+		}
+		if b != backup {
+			tt.Errorf("backup key: %v", b)
+		}
+	})
+}
+
+func TestSetFastOpenNoCookie(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen err: %+v", err)
+	}
+	defer listener.Close()
+
+	if err := SetFastOpenNoCookie(listener, true); err != nil {
+		t.Fatalf("fastopen no_cookie set err: %+v", err)
+	}
+	if err := getFd(listener.(*net.TCPListener), func(fd int) error {
+		if v, err := getsockoptFastOpenNoCookie(fd); err != nil {
+			t.Errorf("fastopen no_cookie get err: %+v", err)
+		} else {
+			if v != 1 {
+				t.Errorf("enable no_cookie: %d", v)
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Errorf("must no error: %+v", err)
+	}
+}
+
+func TestKeyRotator(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen err: %+v", err)
+	}
+	defer listener.Close()
+
+	source := HMACKeySource([]byte("shared secret"))
+	interval := 100 * time.Millisecond
+	r := NewKeyRotator(listener, source, interval, func(err error) {
+		t.Errorf("rotate err: %+v", err)
+	})
+
+	t.Run("rotate", func(tt *testing.T) {
+		now := time.Now()
+		if err := r.Rotate(now); err != nil {
+			tt.Fatalf("rotate err: %+v", err)
+		}
+		expectPrimary, _ := source(r.Epoch(now))
+		expectBackup, _ := source(r.Epoch(now) - 1)
+
+		p, b, err := GetFastOpenKey(listener)
+		if err != nil {
+			tt.Fatalf("fastopen key get err: %+v", err)
+		}
+		if p != expectPrimary {
+			tt.Errorf("primary must be current epoch key")
+		}
+		if b != expectBackup {
+			tt.Errorf("backup must be previous epoch key")
+		}
+	})
+	t.Run("schedule", func(tt *testing.T) {
+		if err := r.Start(); err != nil {
+			tt.Fatalf("start err: %+v", err)
+		}
+		first, _, _ := GetFastOpenKey(listener)
+		time.Sleep(2 * interval)
+		r.Stop()
+
+		second, backup, _ := GetFastOpenKey(listener)
+		if first == second {
+			tt.Errorf("key must be rotated")
+		}
+		if backup == (FastOpenKey{}) {
+			tt.Errorf("backup must be installed")
+		}
+	})
+}
+
+func TestDialFastOpen(t *testing.T) {
+	lc := &net.ListenConfig{
+		Control: func(network, address string, c syscall.RawConn) error {
+			var fdErr error
+			if err := c.Control(func(fd uintptr) {
+				fdErr = setsockoptFastOpen(int(fd), 16)
+			}); err != nil {
+				return err
+			}
+			return fdErr
+		},
+	}
+	listener, err := lc.Listen(context.Background(), "tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen err: %+v", err)
+	}
+	defer listener.Close()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(c net.Conn) {
+				defer c.Close()
+				io.Copy(c, c)
+			}(conn)
+		}
+	}()
+
+	dial := func(tt *testing.T) FastOpenResult {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		conn, result, err := DialFastOpen(ctx, "tcp", listener.Addr().String(), []byte("PING"), Config{EnableNoDelay: true})
+		if err != nil {
+			tt.Fatalf("dial err: %+v", err)
+		}
+		defer conn.Close()
+
+		buf := make([]byte, 4)
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			tt.Fatalf("read err: %+v", err)
+		}
+		if string(buf) != "PING" {
+			tt.Errorf("first payload must be delivered: %q", buf)
+		}
+		return result
+	}
+
+	first := dial(t)
+	t.Logf("first: %+v", first)
+	if first.Accepted {
+		t.Errorf("no cookie cached yet, must not be accepted in SYN")
+	}
+	if first.Fallback != true {
+		t.Errorf("first dial must fall back to a normal handshake")
+	}
+
+	sysctl, err := os.ReadFile("/proc/sys/net/ipv4/tcp_fastopen")
+	if err != nil {
+		t.Skipf("tcp_fastopen sysctl: %+v", err)
+	}
+	mode, _ := strconv.Atoi(strings.TrimSpace(string(sysctl)))
+	if (mode & 0x3) != 0x3 {
+		t.Skipf("net.ipv4.tcp_fastopen=%d, client and server must be enabled", mode)
+	}
+
+	second := dial(t)
+	t.Logf("second: %+v", second)
+	if second.SentInSYN != true || second.Accepted != true {
+		t.Errorf("cookie cached, payload must be accepted in SYN")
+	}
+}
+
+func TestKeyRotatorZeroInterval(t *testing.T) {
+	r := NewKeyRotator(nil, HMACKeySource([]byte("shared secret")), 0, nil)
+	now := time.Now()
+	if expect := now.UnixNano() / int64(DefaultKeyRotateInterval); r.Epoch(now) != expect {
+		t.Errorf("expect epoch %d actual %d", expect, r.Epoch(now))
+	}
+}
@@ -1,5 +1,5 @@
 module github.com/octu0/tcpoption
 
-go 1.17
+go 1.18
 
 require golang.org/x/sys v0.0.0-20220429233432-b5fbb4746d32
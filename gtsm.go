@@ -0,0 +1,173 @@
+package tcpoption
+
+import (
+	"net"
+)
+
+const (
+	GTSMTTL    int = 255
+	GTSMMinTTL int = 254 // RFC 5082, peer is at most one hop away
+)
+
+// SetTTL sets IP_TTL of outgoing packets, also applies to v4-mapped peers of AF_INET6 sockets
+func SetTTL(conn net.Conn, ttl int) error {
+	if c, ok := conn.(*net.TCPConn); ok {
+		return getFd(c, func(fd int) error {
+			return setsockoptTTL(fd, ttl)
+		})
+	}
+	return nil
+}
+
+func SetTTLFd(fd int, ttl int) error {
+	return setsockoptTTL(fd, ttl)
+}
+
+func GetTTL(conn net.Conn) (int, error) {
+	return getIntOpt(conn, getsockoptTTL)
+}
+
+// SetHopLimit sets IPV6_UNICAST_HOPS of outgoing packets
+func SetHopLimit(conn net.Conn, hops int) error {
+	if c, ok := conn.(*net.TCPConn); ok {
+		return getFd(c, func(fd int) error {
+			return setsockoptHopLimit(fd, hops)
+		})
+	}
+	return nil
+}
+
+func SetHopLimitFd(fd int, hops int) error {
+	return setsockoptHopLimit(fd, hops)
+}
+
+func GetHopLimit(conn net.Conn) (int, error) {
+	return getIntOpt(conn, getsockoptHopLimit)
+}
+
+// SetMinTTL drops incoming packets with TTL below ttl (IP_MINTTL and/or IPV6_MINHOPCOUNT by family), linux only
+func SetMinTTL(conn net.Conn, ttl int) error {
+	if c, ok := conn.(*net.TCPConn); ok {
+		return getFd(c, func(fd int) error {
+			return setMinTTLFd(fd, ttl)
+		})
+	}
+	return nil
+}
+
+// SetListenerMinTTL drops SYNs below ttl (IP_MINTTL and/or IPV6_MINHOPCOUNT by family),
+// accepted conns inherit it
+func SetListenerMinTTL(listener net.Listener, ttl int) error {
+	if l, ok := listener.(*net.TCPListener); ok {
+		return getFd(l, func(fd int) error {
+			return setMinTTLFd(fd, ttl)
+		})
+	}
+	return nil
+}
+
+// SetMinTTLFd sets IP_MINTTL only, use SetMinHopCountFd for IPv6
+func SetMinTTLFd(fd int, ttl int) error {
+	return setsockoptMinTTL(fd, ttl)
+}
+
+func GetMinTTL(conn net.Conn) (int, error) {
+	return getIntOpt(conn, getsockoptMinTTL)
+}
+
+// SetMinHopCount drops incoming IPv6 packets with hop limit below hops (IPV6_MINHOPCOUNT), linux only
+func SetMinHopCount(conn net.Conn, hops int) error {
+	if c, ok := conn.(*net.TCPConn); ok {
+		return getFd(c, func(fd int) error {
+			return setsockoptMinHopCount(fd, hops)
+		})
+	}
+	return nil
+}
+
+func SetMinHopCountFd(fd int, hops int) error {
+	return setsockoptMinHopCount(fd, hops)
+}
+
+func GetMinHopCount(conn net.Conn) (int, error) {
+	return getIntOpt(conn, getsockoptMinHopCount)
+}
+
+// GTSM sends with TTL 255 and drops packets below TTL 254 on the family conn uses.
+// apply to the listener (GTSMListener) or Dialer with Config.GTSM so the handshake is also protected.
+func GTSM(conn net.Conn) error {
+	if c, ok := conn.(*net.TCPConn); ok {
+		return getFd(c, GTSMFd)
+	}
+	return nil
+}
+
+func GTSMListener(listener net.Listener) error {
+	if l, ok := listener.(*net.TCPListener); ok {
+		return getFd(l, GTSMFd)
+	}
+	return nil
+}
+
+func GTSMFd(fd int) error {
+	if err := setTTLFd(fd, GTSMTTL); err != nil {
+		return err
+	}
+	return setMinTTLFd(fd, GTSMMinTTL)
+}
+
+// setTTLFd sets TTL and/or hop limit by socket family
+func setTTLFd(fd int, ttl int) error {
+	ipv4, ipv6, err := tosFamily(fd)
+	if err != nil {
+		return err
+	}
+	if ipv6 {
+		if err := setsockoptHopLimit(fd, ttl); err != nil {
+			return err
+		}
+	}
+	if ipv4 {
+		if err := setsockoptTTL(fd, ttl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setMinTTLFd sets IP_MINTTL and/or IPV6_MINHOPCOUNT by socket family
+func setMinTTLFd(fd int, ttl int) error {
+	ipv4, ipv6, err := tosFamily(fd)
+	if err != nil {
+		return err
+	}
+	if ipv6 {
+		if err := setsockoptMinHopCount(fd, ttl); err != nil {
+			return err
+		}
+	}
+	if ipv4 {
+		if err := setsockoptMinTTL(fd, ttl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func getIntOpt(conn net.Conn, getter func(int) (int, error)) (int, error) {
+	if c, ok := conn.(*net.TCPConn); ok {
+		val := 0
+		if err := getFd(c, func(fd int) error {
+			v, err := getter(fd)
+			if err != nil {
+				return err
+			}
+			val = v
+			return nil
+		}); err != nil {
+			return 0, err
+		}
+		return val, nil
+	}
+	return 0, nil
+}
@@ -0,0 +1,76 @@
+package tcpoption
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestGTSM(t *testing.T) {
+	for _, tc := range []struct {
+		network string
+		address string
+	}{
+		{"tcp4", "127.0.0.1:0"},
+		{"tcp6", "[::1]:0"},
+	} {
+		listener, err := Listen(context.TODO(), tc.network, tc.address, Config{GTSM: true})
+		if err != nil {
+			t.Skipf("%s listen err: %+v", tc.network, err)
+		}
+		defer listener.Close()
+		accepted := acceptLoop(t, listener)
+
+		t.Run(tc.network+"/accept", func(tt *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			client, err := Dial(ctx, tc.network, listener.Addr().String(), Config{GTSM: true})
+			if err != nil {
+				tt.Fatalf("dial err: %+v", err)
+			}
+			defer client.Close()
+			server := <-accepted
+			defer server.Close()
+
+			get, min := GetTTL, GetMinTTL
+			if tc.network == "tcp6" {
+				get, min = GetHopLimit, GetMinHopCount
+			}
+			if v, err := get(client); err != nil || v != GTSMTTL {
+				tt.Errorf("outgoing ttl %d, actual:%d %+v", GTSMTTL, v, err)
+			}
+			if v, err := min(server); err != nil || v != GTSMMinTTL {
+				tt.Errorf("accepted conn must inherit min ttl %d, actual:%d %+v", GTSMMinTTL, v, err)
+			}
+		})
+		t.Run(tc.network+"/reject", func(tt *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+			defer cancel()
+
+			// SYN arrives with ttl 253 < 254 and is dropped by the listener
+			client, err := Dial(ctx, tc.network, listener.Addr().String(), Config{TTL: GTSMMinTTL - 1})
+			if err == nil {
+				client.Close()
+				tt.Fatalf("connection with ttl %d must be rejected", GTSMMinTTL-1)
+			}
+			if ne, ok := err.(net.Error); ok != true || ne.Timeout() != true {
+				tt.Errorf("expect timeout, actual:%+v", err)
+			}
+		})
+	}
+}
+
+func TestSetMinTTLFamily(t *testing.T) {
+	client, server := dialPair(t, "tcp6", "[::1]:0", "tcp6")
+	defer client.Close()
+	defer server.Close()
+
+	if err := SetMinTTL(client, GTSMMinTTL); err != nil {
+		t.Fatalf("set err: %+v", err)
+	}
+	if v, err := GetMinHopCount(client); err != nil || v != GTSMMinTTL {
+		t.Errorf("IPv6 conn must set min hop count %d, actual:%d %+v", GTSMMinTTL, v, err)
+	}
+}
@@ -0,0 +1,130 @@
+package tcpoption
+
+import (
+	"errors"
+	"net"
+	"syscall"
+)
+
+type State uint8
+
+const (
+	StateUnknown State = iota
+	StateEstablished
+	StatePeerClosed
+	StateReset
+	StateBroken
+)
+
+func (s State) String() string {
+	switch s {
+	case StateEstablished:
+		return "Established"
+	case StatePeerClosed:
+		return "PeerClosed"
+	case StateReset:
+		return "Reset"
+	case StateBroken:
+		return "Broken"
+	}
+	return "Unknown"
+}
+
+// Health classifies conn without consuming any pending data.
+// non TCP conn returns StateUnknown.
+func Health(conn net.Conn) (State, error) {
+	c, ok := conn.(*net.TCPConn)
+	if ok != true {
+		return StateUnknown, nil
+	}
+	state := StateUnknown
+	if err := getFd(c, func(fd int) error {
+		s, err := HealthFd(fd)
+		if err != nil {
+			return err
+		}
+		state = s
+		return nil
+	}); err != nil {
+		return StateBroken, err
+	}
+	return state, nil
+}
+
+func HealthFd(fd int) (State, error) {
+	errno, err := getsockoptError(fd)
+	if err != nil {
+		return StateBroken, err
+	}
+	if errno != 0 {
+		if isResetError(syscall.Errno(errno)) {
+			return StateReset, nil
+		}
+		return StateBroken, nil
+	}
+
+	state, err := getsockoptTCPState(fd)
+	if err != nil {
+		return StateBroken, err
+	}
+	switch state {
+	case StatePeerClosed, StateReset, StateBroken:
+		return state, nil
+	}
+
+	n, err := recvPeek(fd)
+	if err != nil {
+		if isWouldBlock(err) {
+			return StateEstablished, nil
+		}
+		if isResetError(err) {
+			return StateReset, nil
+		}
+		return StateBroken, nil
+	}
+	if n == 0 {
+		return StatePeerClosed, nil
+	}
+	return StateEstablished, nil // unread data remains in the receive queue
+}
+
+// Reusable reports whether conn can be handed out again by a pool.
+// conn whose state cannot be determined is treated as reusable.
+func Reusable(conn net.Conn) bool {
+	state, err := Health(conn)
+	if err != nil {
+		return false
+	}
+	switch state {
+	case StateEstablished, StateUnknown:
+		return true
+	}
+	return false
+}
+
+type HealthResult struct {
+	Conn  net.Conn
+	State State
+	Err   error
+}
+
+func CheckHealth(conns []net.Conn) []HealthResult {
+	results := make([]HealthResult, len(conns))
+	for i, conn := range conns {
+		state, err := Health(conn)
+		results[i] = HealthResult{
+			Conn:  conn,
+			State: state,
+			Err:   err,
+		}
+	}
+	return results
+}
+
+func isResetError(err error) bool {
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE)
+}
+
+func isWouldBlock(err error) bool {
+	return errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.EWOULDBLOCK)
+}
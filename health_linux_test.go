@@ -0,0 +1,128 @@
+package tcpoption
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func setupConnPair(t testingWrap) (net.Conn, net.Conn) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen err: %+v", err)
+	}
+	defer listener.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			close(accepted)
+			return
+		}
+		accepted <- conn
+	}()
+
+	client, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("client open err: %+v", err)
+	}
+	server, ok := <-accepted
+	if ok != true {
+		t.Fatalf("accept failed")
+	}
+	return client, server
+}
+
+func waitHealth(t *testing.T, conn net.Conn, expect State) {
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		state, err := Health(conn)
+		if err != nil {
+			t.Fatalf("health err: %+v", err)
+		}
+		if state == expect {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	state, _ := Health(conn)
+	t.Errorf("expect %s, actual %s", expect, state)
+}
+
+func TestHealth(t *testing.T) {
+	t.Run("established", func(tt *testing.T) {
+		client, server := setupConnPair(tt)
+		defer client.Close()
+		defer server.Close()
+
+		waitHealth(tt, client, StateEstablished)
+		if Reusable(client) != true {
+			tt.Errorf("established conn must be reusable")
+		}
+	})
+	t.Run("pending_data", func(tt *testing.T) {
+		client, server := setupConnPair(tt)
+		defer client.Close()
+		defer server.Close()
+
+		server.Write([]byte("PING"))
+		time.Sleep(50 * time.Millisecond)
+		waitHealth(tt, client, StateEstablished)
+
+		buf := make([]byte, 4)
+		client.SetReadDeadline(time.Now().Add(time.Second))
+		n, err := client.Read(buf)
+		if err != nil {
+			tt.Fatalf("read err: %+v", err)
+		}
+		if string(buf[:n]) != "PING" {
+			tt.Errorf("health must not consume data: %q", buf[:n])
+		}
+	})
+	t.Run("peer_closed", func(tt *testing.T) {
+		client, server := setupConnPair(tt)
+		defer client.Close()
+
+		server.Close()
+		waitHealth(tt, client, StatePeerClosed)
+		if Reusable(client) {
+			tt.Errorf("peer closed conn must not be reusable")
+		}
+	})
+	t.Run("reset", func(tt *testing.T) {
+		client, server := setupConnPair(tt)
+		defer client.Close()
+
+		if err := SetNoLinger(server, true); err != nil {
+			tt.Fatalf("nolinger err: %+v", err)
+		}
+		server.Close()
+		waitHealth(tt, client, StateReset)
+		if Reusable(client) {
+			tt.Errorf("reset conn must not be reusable")
+		}
+	})
+}
+
+func TestCheckHealth(t *testing.T) {
+	client1, server1 := setupConnPair(t)
+	defer client1.Close()
+	defer server1.Close()
+
+	client2, server2 := setupConnPair(t)
+	defer client2.Close()
+	server2.Close()
+	time.Sleep(50 * time.Millisecond)
+
+	results := CheckHealth([]net.Conn{client1, client2})
+	if len(results) != 2 {
+		t.Fatalf("result size: %d", len(results))
+	}
+	if results[0].Conn != client1 || results[0].State != StateEstablished {
+		t.Errorf("client1 expect established: %s", results[0].State)
+	}
+	if results[1].Conn != client2 || results[1].State != StatePeerClosed {
+		t.Errorf("client2 expect peer closed: %s", results[1].State)
+	}
+}
@@ -0,0 +1,524 @@
+package tcpoption
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+const (
+	tlsRecordHeaderLen      int   = 5
+	tlsMaxPlaintext         int   = 16384
+	tlsRecordChangeCipher   uint8 = 20
+	tlsRecordAlert          uint8 = 21
+	tlsRecordHandshake      uint8 = 22
+	tlsRecordAppData        uint8 = 23
+	tlsHandshakeServerHello uint8 = 2
+	tlsHandshakeNewTicket   uint8 = 4
+	tlsAlertCloseNotify     uint8 = 0
+)
+
+type ktlsCipherType uint8
+
+const (
+	ktlsAESGCM128 ktlsCipherType = iota + 1
+	ktlsAESGCM256
+	ktlsChaCha20Poly1305
+)
+
+type ktlsDirection uint8
+
+const (
+	ktlsTX ktlsDirection = iota + 1
+	ktlsRX
+)
+
+var errKTLSCipher = errors.New("ktls: cipher suite is not offloadable")
+
+type ktlsSuite struct {
+	cipher ktlsCipherType
+	keyLen int
+	ivLen  int // TLS 1.2 implicit iv (fixed_iv_length)
+	hash   func() hash.Hash
+}
+
+var ktlsSuites = map[uint16]ktlsSuite{
+	tls.TLS_AES_128_GCM_SHA256:                        {ktlsAESGCM128, 16, 4, sha256.New},
+	tls.TLS_AES_256_GCM_SHA384:                        {ktlsAESGCM256, 32, 4, sha512.New384},
+	tls.TLS_CHACHA20_POLY1305_SHA256:                  {ktlsChaCha20Poly1305, 32, 12, sha256.New},
+	tls.TLS_RSA_WITH_AES_128_GCM_SHA256:               {ktlsAESGCM128, 16, 4, sha256.New},
+	tls.TLS_RSA_WITH_AES_256_GCM_SHA384:               {ktlsAESGCM256, 32, 4, sha512.New384},
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256:         {ktlsAESGCM128, 16, 4, sha256.New},
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384:         {ktlsAESGCM256, 32, 4, sha512.New384},
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256:       {ktlsAESGCM128, 16, 4, sha256.New},
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384:       {ktlsAESGCM256, 32, 4, sha512.New384},
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256:   {ktlsChaCha20Poly1305, 32, 12, sha256.New},
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256: {ktlsChaCha20Poly1305, 32, 12, sha256.New},
+}
+
+// ktlsCipher is one direction of record protection handed over to the kernel
+type ktlsCipher struct {
+	version uint16 // tls.VersionTLS12 or tls.VersionTLS13
+	cipher  ktlsCipherType
+	key     []byte
+	iv      []byte // TLS 1.3 and ChaCha20: 12 bytes nonce base, TLS 1.2 AES-GCM: 4 bytes salt
+	seq     uint64 // sequence number of the next record
+}
+
+// KTLSClient returns client side *tls.Conn that EnableKTLS can hand over to the kernel.
+// KeyLogWriter of cfg still receives the key log.
+func KTLSClient(conn net.Conn, cfg *tls.Config) *tls.Conn {
+	r := newKTLSRecorder(conn, true)
+	return tls.Client(r, r.config(cfg))
+}
+
+// KTLSServer returns server side *tls.Conn that EnableKTLS can hand over to the kernel.
+// session tickets are disabled, crypto/tls sends TLS 1.3 tickets under the traffic key
+// before the handshake returns which leaves the record sequence unknown.
+// configs returned by GetConfigForClient bypass the key log and are not offloaded.
+func KTLSServer(conn net.Conn, cfg *tls.Config) *tls.Conn {
+	r := newKTLSRecorder(conn, false)
+	c := r.config(cfg)
+	c.SessionTicketsDisabled = true
+	return tls.Server(r, c)
+}
+
+// EnableKTLS completes the handshake of tconn and installs the "tls" ULP (TCP_ULP) with
+// TLS_TX/TLS_RX crypto info, requires kernel 4.13+ (TX) / 4.17+ (RX) with CONFIG_TLS.
+// tconn must come from KTLSClient or KTLSServer and must not have been read or written yet.
+// returned conn reads and writes plaintext on the socket, ReadFrom uses sendfile(2).
+// offloaded reports false with tconn itself when the tls module or the cipher suite
+// is not available, tconn keeps working in userspace.
+// when TLS_TX is refused after the ULP was attached (e.g. cipher missing in the kernel)
+// the socket stays ULP-attached, a ULP cannot be detached and passes records through.
+func EnableKTLS(tconn *tls.Conn) (net.Conn, bool, error) {
+	r, ok := tconn.NetConn().(*ktlsRecorder)
+	if ok != true {
+		return nil, false, fmt.Errorf("ktls: conn is not created by KTLSClient or KTLSServer")
+	}
+	if err := tconn.Handshake(); err != nil {
+		return nil, false, err
+	}
+	defer r.passthrough()
+
+	tx, rx, err := r.cipherInfo(tconn.ConnectionState())
+	if err != nil {
+		if errors.Is(err, errKTLSCipher) {
+			return tconn, false, nil
+		}
+		return nil, false, err
+	}
+	tcp, ok := r.Conn.(*net.TCPConn)
+	if ok != true {
+		return tconn, false, nil
+	}
+	if r.buffered() {
+		return tconn, false, nil
+	}
+
+	if err := getFd(tcp, func(fd int) error {
+		return setsockoptULP(fd, "tls")
+	}); err != nil {
+		if isKTLSUnavailable(err) {
+			return tconn, false, nil
+		}
+		return nil, false, err
+	}
+	// ULP stays attached from here on, without crypto info it passes records through
+	// so tconn still works in userspace when TLS_TX is refused
+	if err := getFd(tcp, func(fd int) error {
+		return setsockoptKTLS(fd, ktlsTX, tx)
+	}); err != nil {
+		if isKTLSUnavailable(err) || errors.Is(err, syscall.EINVAL) {
+			return tconn, false, nil
+		}
+		return nil, false, err
+	}
+	if err := getFd(tcp, func(fd int) error {
+		return setsockoptKTLS(fd, ktlsRX, rx)
+	}); err != nil {
+		return nil, false, fmt.Errorf("ktls: TLS_RX failed after TLS_TX was installed: %w", err)
+	}
+
+	raw, err := tcp.SyscallConn()
+	if err != nil {
+		return nil, false, err
+	}
+	return &KTLSConn{Conn: tcp, tcp: tcp, raw: raw, version: tx.version}, true, nil
+}
+
+func isKTLSUnavailable(err error) bool {
+	return errors.Is(err, syscall.ENOENT) || // tls module not found
+		errors.Is(err, syscall.ENOPROTOOPT) ||
+		errors.Is(err, syscall.EOPNOTSUPP) ||
+		errors.Is(err, syscall.EEXIST) // another ULP is installed
+}
+
+// KTLSConn is a TLS connection whose records are sealed and opened by the kernel
+type KTLSConn struct {
+	net.Conn
+
+	tcp       *net.TCPConn
+	raw       syscall.RawConn
+	version   uint16
+	rmutex    sync.Mutex
+	buf       []byte
+	pending   []byte
+	closeOnce sync.Once
+}
+
+// Read returns application data, close_notify alert reads as io.EOF.
+// TLS 1.3 NewSessionTicket is skipped, KeyUpdate is not supported and fails the read.
+func (c *KTLSConn) Read(p []byte) (int, error) {
+	c.rmutex.Lock()
+	defer c.rmutex.Unlock()
+
+	if 0 < len(c.pending) {
+		n := copy(p, c.pending)
+		c.pending = c.pending[n:]
+		return n, nil
+	}
+	for {
+		// records larger than p are received into buf, control records must be read whole
+		buf := p
+		if len(p) < tlsMaxPlaintext {
+			if c.buf == nil {
+				c.buf = make([]byte, tlsMaxPlaintext)
+			}
+			buf = c.buf
+		}
+		n, typ, err := c.recv(buf)
+		if err != nil {
+			return 0, err
+		}
+		switch typ {
+		case tlsRecordAppData:
+			if n == 0 {
+				return 0, io.EOF
+			}
+			if len(p) < tlsMaxPlaintext {
+				m := copy(p, buf[:n])
+				c.pending = buf[m:n]
+				return m, nil
+			}
+			return n, nil
+		case tlsRecordAlert:
+			if n < 2 {
+				return 0, fmt.Errorf("ktls: malformed alert")
+			}
+			if buf[1] == tlsAlertCloseNotify {
+				return 0, io.EOF
+			}
+			return 0, fmt.Errorf("ktls: received alert %d", buf[1])
+		case tlsRecordHandshake:
+			if c.version == tls.VersionTLS13 && 0 < n && buf[0] == tlsHandshakeNewTicket {
+				continue
+			}
+			if 0 < n {
+				return 0, fmt.Errorf("ktls: unsupported handshake message %d", buf[0])
+			}
+			return 0, fmt.Errorf("ktls: malformed handshake record")
+		default:
+			return 0, fmt.Errorf("ktls: unexpected record type %d", typ)
+		}
+	}
+}
+
+func (c *KTLSConn) recv(p []byte) (int, uint8, error) {
+	n, typ := 0, uint8(0)
+	var rerr error
+	if err := c.raw.Read(func(fd uintptr) bool {
+		n, typ, rerr = recvTLSRecord(int(fd), p)
+		return rerr != syscall.EAGAIN
+	}); err != nil {
+		return 0, 0, err
+	}
+	if rerr != nil {
+		return 0, 0, os.NewSyscallError("recvmsg", rerr)
+	}
+	return n, typ, nil
+}
+
+// ReadFrom sends r with sendfile(2)/splice(2) when possible, the kernel encrypts the records
+func (c *KTLSConn) ReadFrom(r io.Reader) (int64, error) {
+	return c.tcp.ReadFrom(r)
+}
+
+// Close sends close_notify alert then closes the socket
+func (c *KTLSConn) Close() error {
+	c.closeOnce.Do(func() {
+		c.raw.Write(func(fd uintptr) bool {
+			return sendTLSAlert(int(fd), []byte{1, tlsAlertCloseNotify}) != syscall.EAGAIN
+		})
+	})
+	return c.tcp.Close()
+}
+
+// ktlsRecorder sits under crypto/tls and reads one record at a time so that
+// no record beyond the handshake is buffered in userspace, it also keeps
+// the randoms and the record counts the kernel crypto info needs.
+type ktlsRecorder struct {
+	net.Conn
+
+	isClient bool
+
+	rmutex  sync.Mutex
+	rbuf    []byte
+	rpend   []byte
+	wmutex  sync.Mutex
+	wbuf    []byte
+	in, out ktlsRecordLog
+
+	mutex        sync.Mutex
+	direct       bool
+	secrets      map[string][]byte
+	clientRandom []byte
+	serverRandom []byte
+}
+
+type ktlsRecordLog struct {
+	afterCCS uint64 // records since the last ChangeCipherSpec
+	ccs      bool
+}
+
+func newKTLSRecorder(conn net.Conn, isClient bool) *ktlsRecorder {
+	return &ktlsRecorder{
+		Conn:     conn,
+		isClient: isClient,
+		secrets:  make(map[string][]byte),
+	}
+}
+
+func (r *ktlsRecorder) config(cfg *tls.Config) *tls.Config {
+	c := &tls.Config{}
+	if cfg != nil {
+		c = cfg.Clone()
+	}
+	c.KeyLogWriter = &ktlsKeyLog{r, c.KeyLogWriter}
+	return c
+}
+
+func (r *ktlsRecorder) passthrough() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.direct = true
+}
+
+func (r *ktlsRecorder) isDirect() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.direct
+}
+
+func (r *ktlsRecorder) buffered() bool {
+	r.rmutex.Lock()
+	defer r.rmutex.Unlock()
+	return 0 < len(r.rbuf) || 0 < len(r.rpend)
+}
+
+func (r *ktlsRecorder) Read(p []byte) (int, error) {
+	r.rmutex.Lock()
+	defer r.rmutex.Unlock()
+
+	if len(r.rpend) == 0 && len(r.rbuf) == 0 && r.isDirect() {
+		return r.Conn.Read(p)
+	}
+	for len(r.rpend) == 0 {
+		if err := r.fill(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.rpend)
+	r.rpend = r.rpend[n:]
+	return n, nil
+}
+
+// fill reads exactly one record from the socket
+func (r *ktlsRecorder) fill() error {
+	for {
+		need := tlsRecordHeaderLen
+		if tlsRecordHeaderLen <= len(r.rbuf) {
+			need += int(binary.BigEndian.Uint16(r.rbuf[3:5]))
+			if need <= len(r.rbuf) {
+				r.observe(&r.in, r.rbuf)
+				r.rpend = r.rbuf
+				r.rbuf = nil
+				return nil
+			}
+		}
+		buf := make([]byte, need-len(r.rbuf))
+		n, err := r.Conn.Read(buf)
+		r.rbuf = append(r.rbuf, buf[:n]...)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (r *ktlsRecorder) Write(p []byte) (int, error) {
+	r.wmutex.Lock()
+	defer r.wmutex.Unlock()
+
+	if r.isDirect() {
+		return r.Conn.Write(p)
+	}
+	n, err := r.Conn.Write(p)
+	r.wbuf = append(r.wbuf, p[:n]...)
+	for tlsRecordHeaderLen <= len(r.wbuf) {
+		size := tlsRecordHeaderLen + int(binary.BigEndian.Uint16(r.wbuf[3:5]))
+		if len(r.wbuf) < size {
+			break
+		}
+		r.observe(&r.out, r.wbuf[:size])
+		r.wbuf = r.wbuf[size:]
+	}
+	return n, err
+}
+
+func (r *ktlsRecorder) observe(log *ktlsRecordLog, record []byte) {
+	switch record[0] {
+	case tlsRecordChangeCipher:
+		log.ccs = true
+		log.afterCCS = 0
+		return
+	case tlsRecordHandshake:
+		// handshake type(1) length(3) version(2) random(32)
+		if log.ccs != true && tlsRecordHeaderLen+38 <= len(record) && record[tlsRecordHeaderLen] == tlsHandshakeServerHello {
+			r.mutex.Lock()
+			r.serverRandom = append([]byte(nil), record[tlsRecordHeaderLen+6:tlsRecordHeaderLen+38]...)
+			r.mutex.Unlock()
+		}
+	}
+	if log.ccs {
+		log.afterCCS += 1
+	}
+}
+
+// cipherInfo derives TX and RX crypto info from the key log (NSS key log format)
+func (r *ktlsRecorder) cipherInfo(state tls.ConnectionState) (ktlsCipher, ktlsCipher, error) {
+	suite, ok := ktlsSuites[state.CipherSuite]
+	if ok != true {
+		return ktlsCipher{}, ktlsCipher{}, errKTLSCipher
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	client := ktlsCipher{version: state.Version, cipher: suite.cipher}
+	server := ktlsCipher{version: state.Version, cipher: suite.cipher}
+	switch state.Version {
+	case tls.VersionTLS13:
+		cs, ok1 := r.secrets["CLIENT_TRAFFIC_SECRET_0"]
+		ss, ok2 := r.secrets["SERVER_TRAFFIC_SECRET_0"]
+		if ok1 != true || ok2 != true {
+			return ktlsCipher{}, ktlsCipher{}, fmt.Errorf("ktls: traffic secrets are missing from key log")
+		}
+		client.key = hkdfExpandLabel(suite.hash, cs, "key", suite.keyLen)
+		client.iv = hkdfExpandLabel(suite.hash, cs, "iv", 12)
+		server.key = hkdfExpandLabel(suite.hash, ss, "key", suite.keyLen)
+		server.iv = hkdfExpandLabel(suite.hash, ss, "iv", 12)
+		// no record is protected with the traffic keys before the handshake returns
+	case tls.VersionTLS12:
+		master, ok := r.secrets["CLIENT_RANDOM"]
+		if ok != true || len(r.clientRandom) != 32 || len(r.serverRandom) != 32 {
+			return ktlsCipher{}, ktlsCipher{}, fmt.Errorf("ktls: master secret or randoms are missing")
+		}
+		seed := append(append([]byte(nil), r.serverRandom...), r.clientRandom...)
+		kb := prf12(suite.hash, master, "key expansion", seed, 2*suite.keyLen+2*suite.ivLen)
+		client.key = kb[0:suite.keyLen]
+		server.key = kb[suite.keyLen : 2*suite.keyLen]
+		client.iv = kb[2*suite.keyLen : 2*suite.keyLen+suite.ivLen]
+		server.iv = kb[2*suite.keyLen+suite.ivLen:]
+		// Finished is the first record under the new keys
+		if r.isClient {
+			client.seq, server.seq = r.out.afterCCS, r.in.afterCCS
+		} else {
+			client.seq, server.seq = r.in.afterCCS, r.out.afterCCS
+		}
+	default:
+		return ktlsCipher{}, ktlsCipher{}, errKTLSCipher
+	}
+	if r.isClient {
+		return client, server, nil
+	}
+	return server, client, nil
+}
+
+// ktlsKeyLog receives crypto/tls key log lines: <label> <client_random> <secret>
+type ktlsKeyLog struct {
+	r    *ktlsRecorder
+	next io.Writer
+}
+
+func (k *ktlsKeyLog) Write(line []byte) (int, error) {
+	fields := strings.Fields(string(line))
+	if len(fields) == 3 {
+		random, err1 := hex.DecodeString(fields[1])
+		secret, err2 := hex.DecodeString(fields[2])
+		if err1 == nil && err2 == nil {
+			k.r.mutex.Lock()
+			k.r.clientRandom = random
+			k.r.secrets[fields[0]] = secret
+			k.r.mutex.Unlock()
+		}
+	}
+	if k.next != nil {
+		return k.next.Write(line)
+	}
+	return len(line), nil
+}
+
+// RFC 5869 HKDF-Expand
+func hkdfExpand(h func() hash.Hash, prk, info []byte, length int) []byte {
+	out := make([]byte, 0, length)
+	t := []byte(nil)
+	for i := byte(1); len(out) < length; i += 1 {
+		m := hmac.New(h, prk)
+		m.Write(t)
+		m.Write(info)
+		m.Write([]byte{i})
+		t = m.Sum(nil)
+		out = append(out, t...)
+	}
+	return out[:length]
+}
+
+// RFC 8446 7.1 HKDF-Expand-Label with empty context
+func hkdfExpandLabel(h func() hash.Hash, secret []byte, label string, length int) []byte {
+	full := "tls13 " + label
+	info := make([]byte, 0, 4+len(full))
+	info = append(info, byte(length>>8), byte(length), byte(len(full)))
+	info = append(info, full...)
+	info = append(info, 0)
+	return hkdfExpand(h, secret, info, length)
+}
+
+// RFC 5246 5 PRF with P_hash
+func prf12(h func() hash.Hash, secret []byte, label string, seed []byte, length int) []byte {
+	labelSeed := append([]byte(label), seed...)
+	out := make([]byte, 0, length)
+	a := labelSeed
+	for len(out) < length {
+		m := hmac.New(h, secret)
+		m.Write(a)
+		a = m.Sum(nil)
+
+		m = hmac.New(h, secret)
+		m.Write(a)
+		m.Write(labelSeed)
+		out = append(out, m.Sum(nil)...)
+	}
+	return out[:length]
+}
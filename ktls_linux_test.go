@@ -0,0 +1,357 @@
+package tcpoption
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func ktlsCertificate(t *testing.T) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key err: %+v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-1 * time.Hour),
+		NotAfter:     time.Now().Add(1 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate err: %+v", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+type ktlsTestCase struct {
+	name    string
+	version uint16
+	suite   uint16
+}
+
+var ktlsTestCases = []ktlsTestCase{
+	{"tls13", tls.VersionTLS13, 0},
+	{"tls12/aes128gcm", tls.VersionTLS12, tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256},
+	{"tls12/aes256gcm", tls.VersionTLS12, tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384},
+	{"tls12/chacha20poly1305", tls.VersionTLS12, tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256},
+}
+
+func ktlsConfigs(t *testing.T, tc ktlsTestCase) (*tls.Config, *tls.Config) {
+	server := &tls.Config{
+		Certificates: []tls.Certificate{ktlsCertificate(t)},
+		MinVersion:   tc.version,
+		MaxVersion:   tc.version,
+	}
+	client := &tls.Config{
+		InsecureSkipVerify: true,
+		MinVersion:         tc.version,
+		MaxVersion:         tc.version,
+	}
+	if tc.suite != 0 {
+		server.CipherSuites = []uint16{tc.suite}
+		client.CipherSuites = []uint16{tc.suite}
+	}
+	return server, client
+}
+
+func TestKTLSInterop(t *testing.T) {
+	payload := bytes.Repeat([]byte("0123456789abcdef"), 64*1024) // spans many records
+	path := filepath.Join(t.TempDir(), "payload")
+	if err := os.WriteFile(path, payload, 0600); err != nil {
+		t.Fatalf("write payload err: %+v", err)
+	}
+
+	for _, tc := range ktlsTestCases {
+		tc := tc
+		t.Run(tc.name+"/server", func(tt *testing.T) {
+			serverCfg, clientCfg := ktlsConfigs(tt, tc)
+			client, server := dialPair(tt, "tcp4", "127.0.0.1:0", "tcp4")
+			defer client.Close()
+			defer server.Close()
+
+			peer := tls.Client(client, clientCfg)
+			errCh := make(chan error, 1)
+			go func() {
+				errCh <- peer.Handshake()
+			}()
+
+			conn, offloaded, err := EnableKTLS(KTLSServer(server, serverCfg))
+			if err != nil {
+				tt.Fatalf("EnableKTLS err: %+v", err)
+			}
+			if err := <-errCh; err != nil {
+				tt.Fatalf("peer handshake err: %+v", err)
+			}
+			tt.Logf("offloaded = %v", offloaded)
+			if _, ok := conn.(*KTLSConn); ok != offloaded {
+				tt.Errorf("offloaded=%v but conn is %T", offloaded, conn)
+			}
+
+			// peer -> offloaded
+			go peer.Write([]byte("ping"))
+			buf := make([]byte, 4)
+			if _, err := io.ReadFull(conn, buf); err != nil {
+				tt.Fatalf("read err: %+v", err)
+			}
+			if string(buf) != "ping" {
+				tt.Errorf("expect ping actual %q", buf)
+			}
+
+			// offloaded -> peer, *os.File goes through sendfile when offloaded
+			go func() {
+				f, err := os.Open(path)
+				if err != nil {
+					errCh <- err
+					return
+				}
+				defer f.Close()
+				_, err = io.Copy(conn, f)
+				if err == nil {
+					err = conn.Close()
+				}
+				errCh <- err
+			}()
+			received, err := io.ReadAll(peer)
+			if err != nil {
+				tt.Fatalf("peer read err: %+v", err)
+			}
+			if err := <-errCh; err != nil {
+				tt.Fatalf("send err: %+v", err)
+			}
+			if bytes.Equal(received, payload) != true {
+				tt.Errorf("payload mismatch: %d bytes received, expect %d", len(received), len(payload))
+			}
+			if offloaded != true {
+				tt.Skipf("kernel tls not available, records were exchanged in userspace")
+			}
+		})
+		t.Run(tc.name+"/client", func(tt *testing.T) {
+			serverCfg, clientCfg := ktlsConfigs(tt, tc)
+			client, server := dialPair(tt, "tcp4", "127.0.0.1:0", "tcp4")
+			defer client.Close()
+			defer server.Close()
+
+			peer := tls.Server(server, serverCfg)
+			errCh := make(chan error, 1)
+			go func() {
+				errCh <- peer.Handshake()
+			}()
+
+			conn, offloaded, err := EnableKTLS(KTLSClient(client, clientCfg))
+			if err != nil {
+				tt.Fatalf("EnableKTLS err: %+v", err)
+			}
+			if err := <-errCh; err != nil {
+				tt.Fatalf("peer handshake err: %+v", err)
+			}
+			tt.Logf("offloaded = %v", offloaded)
+
+			go func() {
+				_, err := conn.Write([]byte("hello"))
+				errCh <- err
+			}()
+			buf := make([]byte, 5)
+			if _, err := io.ReadFull(peer, buf); err != nil {
+				tt.Fatalf("peer read err: %+v", err)
+			}
+			if err := <-errCh; err != nil {
+				tt.Fatalf("write err: %+v", err)
+			}
+			if string(buf) != "hello" {
+				tt.Errorf("expect hello actual %q", buf)
+			}
+
+			// TLS 1.3 NewSessionTicket from the peer arrives before the data
+			go func() {
+				_, err := peer.Write([]byte("world"))
+				if err == nil {
+					err = peer.Close()
+				}
+				errCh <- err
+			}()
+			received, err := io.ReadAll(conn)
+			if err != nil {
+				tt.Fatalf("read err: %+v", err)
+			}
+			if err := <-errCh; err != nil {
+				tt.Fatalf("peer write err: %+v", err)
+			}
+			if string(received) != "world" {
+				tt.Errorf("expect world actual %q", received)
+			}
+			if offloaded != true {
+				tt.Skipf("kernel tls not available, records were exchanged in userspace")
+			}
+			if _, ok := conn.(*KTLSConn); ok != true {
+				tt.Errorf("offloaded but conn is %T", conn)
+			}
+		})
+	}
+}
+
+// captureConn records bytes read from the socket
+type captureConn struct {
+	net.Conn
+	mutex sync.Mutex
+	read  []byte
+}
+
+func (c *captureConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.mutex.Lock()
+	c.read = append(c.read, p[:n]...)
+	c.mutex.Unlock()
+	return n, err
+}
+
+func (c *captureConn) lastRecord() []byte {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	last := []byte(nil)
+	for b := c.read; tlsRecordHeaderLen <= len(b); {
+		size := tlsRecordHeaderLen + int(binary.BigEndian.Uint16(b[3:5]))
+		last, b = b[:size], b[size:]
+	}
+	return last
+}
+
+// openKTLSRecord decrypts AES-GCM record the way the kernel does with crypto info c
+func openKTLSRecord(t *testing.T, c ktlsCipher, record []byte) []byte {
+	block, err := aes.NewCipher(c.key)
+	if err != nil {
+		t.Fatalf("aes err: %+v", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatalf("gcm err: %+v", err)
+	}
+	seq := make([]byte, 8)
+	binary.BigEndian.PutUint64(seq, c.seq)
+
+	if c.version == tls.VersionTLS13 {
+		nonce := append([]byte(nil), c.iv...)
+		for i := 0; i < 8; i += 1 {
+			nonce[4+i] ^= seq[i]
+		}
+		plain, err := aead.Open(nil, nonce, record[tlsRecordHeaderLen:], record[:tlsRecordHeaderLen])
+		if err != nil {
+			t.Fatalf("open tls13 record(seq=%d) err: %+v", c.seq, err)
+		}
+		plain = bytes.TrimRight(plain, "\x00")
+		if plain[len(plain)-1] != tlsRecordAppData {
+			t.Fatalf("inner content type = %d", plain[len(plain)-1])
+		}
+		return plain[:len(plain)-1]
+	}
+
+	payload := record[tlsRecordHeaderLen:]
+	if bytes.Equal(payload[:8], seq) != true {
+		t.Errorf("explicit nonce %x is not the sequence number %x", payload[:8], seq)
+	}
+	nonce := append(append([]byte(nil), c.iv...), payload[:8]...)
+	ad := make([]byte, 13)
+	copy(ad, seq)
+	ad[8] = record[0]
+	copy(ad[9:11], record[1:3])
+	binary.BigEndian.PutUint16(ad[11:13], uint16(len(payload)-8-aead.Overhead()))
+	plain, err := aead.Open(nil, nonce, payload[8:], ad)
+	if err != nil {
+		t.Fatalf("open tls12 record(seq=%d) err: %+v", c.seq, err)
+	}
+	return plain
+}
+
+func TestKTLSCipherInfo(t *testing.T) {
+	for _, tc := range ktlsTestCases {
+		tc := tc
+		t.Run(tc.name, func(tt *testing.T) {
+			serverCfg, clientCfg := ktlsConfigs(tt, tc)
+			client, server := dialPair(tt, "tcp4", "127.0.0.1:0", "tcp4")
+			defer client.Close()
+			defer server.Close()
+
+			clientCapture := &captureConn{Conn: client}
+			serverCapture := &captureConn{Conn: server}
+			ct := KTLSClient(clientCapture, clientCfg)
+			st := KTLSServer(serverCapture, serverCfg)
+			errCh := make(chan error, 1)
+			go func() {
+				errCh <- ct.Handshake()
+			}()
+			if err := st.Handshake(); err != nil {
+				tt.Fatalf("server handshake err: %+v", err)
+			}
+			if err := <-errCh; err != nil {
+				tt.Fatalf("client handshake err: %+v", err)
+			}
+
+			cTX, cRX, err := ct.NetConn().(*ktlsRecorder).cipherInfo(ct.ConnectionState())
+			if err != nil {
+				tt.Fatalf("client cipherInfo err: %+v", err)
+			}
+			sTX, sRX, err := st.NetConn().(*ktlsRecorder).cipherInfo(st.ConnectionState())
+			if err != nil {
+				tt.Fatalf("server cipherInfo err: %+v", err)
+			}
+			for _, pair := range [][2]ktlsCipher{{cTX, sRX}, {sTX, cRX}} {
+				a, b := pair[0], pair[1]
+				if bytes.Equal(a.key, b.key) != true || bytes.Equal(a.iv, b.iv) != true || a.seq != b.seq {
+					tt.Errorf("tx/rx mismatch: %+v != %+v", a, b)
+				}
+			}
+			if size := len(ktlsCryptoInfo(cTX)); size != 40 && size != 56 {
+				tt.Errorf("crypto info size = %d", size)
+			}
+			if cTX.cipher == ktlsChaCha20Poly1305 {
+				tt.Logf("chacha20-poly1305 records are not opened, no AEAD in std")
+				return
+			}
+
+			go ct.Write([]byte("hello"))
+			buf := make([]byte, 5)
+			if _, err := io.ReadFull(st, buf); err != nil {
+				tt.Fatalf("server read err: %+v", err)
+			}
+			if plain := openKTLSRecord(tt, sRX, serverCapture.lastRecord()); string(plain) != "hello" {
+				tt.Errorf("server rx opened %q", plain)
+			}
+
+			go st.Write([]byte("world"))
+			if _, err := io.ReadFull(ct, buf); err != nil {
+				tt.Fatalf("client read err: %+v", err)
+			}
+			if plain := openKTLSRecord(tt, cRX, clientCapture.lastRecord()); string(plain) != "world" {
+				tt.Errorf("client rx opened %q", plain)
+			}
+		})
+	}
+}
+
+func TestKTLSNotPrepared(t *testing.T) {
+	client, server := dialPair(t, "tcp4", "127.0.0.1:0", "tcp4")
+	defer client.Close()
+	defer server.Close()
+
+	if _, _, err := EnableKTLS(tls.Client(client, &tls.Config{})); err == nil {
+		t.Errorf("tls.Client conn must be rejected")
+	}
+}
@@ -0,0 +1,84 @@
+package tcpoption
+
+import (
+	"net"
+)
+
+const (
+	// SO_PRIORITY above this requires CAP_NET_ADMIN
+	MaxUnprivilegedPriority int = 6
+)
+
+// SetMark sets SO_MARK (fwmark) used by policy routing and netfilter, requires CAP_NET_ADMIN.
+// it only affects the SYN when set before connect, use Dialer or SetMarkFd in Control.
+func SetMark(conn net.Conn, mark uint32) error {
+	if c, ok := conn.(*net.TCPConn); ok {
+		return getFd(c, func(fd int) error {
+			return setsockoptMark(fd, mark)
+		})
+	}
+	return nil
+}
+
+func SetMarkFd(fd int, mark uint32) error {
+	return setsockoptMark(fd, mark)
+}
+
+func GetMark(conn net.Conn) (uint32, error) {
+	if c, ok := conn.(*net.TCPConn); ok {
+		mark := uint32(0)
+		if err := getFd(c, func(fd int) error {
+			v, err := getsockoptMark(fd)
+			if err != nil {
+				return err
+			}
+			mark = v
+			return nil
+		}); err != nil {
+			return 0, err
+		}
+		return mark, nil
+	}
+	return 0, nil
+}
+
+func GetMarkFd(fd int) (uint32, error) {
+	return getsockoptMark(fd)
+}
+
+// SetPriority sets SO_PRIORITY (skb->priority) used by qdisc classification,
+// values above MaxUnprivilegedPriority require CAP_NET_ADMIN.
+func SetPriority(conn net.Conn, priority int) error {
+	if c, ok := conn.(*net.TCPConn); ok {
+		return getFd(c, func(fd int) error {
+			return setsockoptPriority(fd, priority)
+		})
+	}
+	return nil
+}
+
+func SetPriorityFd(fd int, priority int) error {
+	return setsockoptPriority(fd, priority)
+}
+
+func GetPriority(conn net.Conn) (int, error) {
+	if c, ok := conn.(*net.TCPConn); ok {
+		priority := 0
+		if err := getFd(c, func(fd int) error {
+			v, err := getsockoptPriority(fd)
+			if err != nil {
+				return err
+			}
+			priority = v
+			return nil
+		}); err != nil {
+			return 0, err
+		}
+		return priority, nil
+	}
+	return 0, nil
+}
+
+func GetPriorityFd(fd int) (int, error) {
+	return getsockoptPriority(fd)
+}
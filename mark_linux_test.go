@@ -0,0 +1,133 @@
+package tcpoption
+
+import (
+	"context"
+	"net"
+	"testing"
+)
+
+func TestSetMark(t *testing.T) {
+	client, server := setupConnPair(t)
+	defer client.Close()
+	defer server.Close()
+
+	if err := SetMark(client, 0x2a); err != nil {
+		if IsPrivilegeError(err) {
+			t.Skipf("not privileged: %+v", err)
+		}
+		t.Fatalf("mark set err: %+v", err)
+	}
+	mark, err := GetMark(client)
+	if err != nil {
+		t.Fatalf("mark get err: %+v", err)
+	}
+	if mark != 0x2a {
+		t.Errorf("mark 0x2a, actual:%#x", mark)
+	}
+}
+
+func TestSetPriority(t *testing.T) {
+	t.Run("unprivileged", func(tt *testing.T) {
+		client, server := setupConnPair(tt)
+		defer client.Close()
+		defer server.Close()
+
+		if err := SetPriority(client, 4); err != nil {
+			tt.Fatalf("priority set err: %+v", err)
+		}
+		priority, err := GetPriority(client)
+		if err != nil {
+			tt.Fatalf("priority get err: %+v", err)
+		}
+		if priority != 4 {
+			tt.Errorf("priority 4, actual:%d", priority)
+		}
+	})
+	t.Run("privileged", func(tt *testing.T) {
+		client, server := setupConnPair(tt)
+		defer client.Close()
+		defer server.Close()
+
+		if err := SetPriority(client, MaxUnprivilegedPriority+1); err != nil {
+			if IsPrivilegeError(err) {
+				tt.Skipf("not privileged: %+v", err)
+			}
+			tt.Fatalf("priority set err: %+v", err)
+		}
+		priority, err := GetPriority(client)
+		if err != nil {
+			tt.Fatalf("priority get err: %+v", err)
+		}
+		if priority != MaxUnprivilegedPriority+1 {
+			tt.Errorf("priority %d, actual:%d", MaxUnprivilegedPriority+1, priority)
+		}
+	})
+}
+
+func TestDialerPreConnect(t *testing.T) {
+	cfg := Config{
+		EnableNoDelay: true,
+		Mark:          0x100,
+		Priority:      3,
+		MaxSeg:        1000,
+	}
+	listener, err := Listen(context.TODO(), "tcp", "127.0.0.1:0", cfg)
+	if err != nil {
+		if IsPrivilegeError(err) {
+			t.Skipf("not privileged: %+v", err)
+		}
+		t.Fatalf("listen err: %+v", err)
+	}
+	defer listener.Close()
+	if _, ok := listener.(*net.TCPListener); ok != true {
+		t.Errorf("listener must be *net.TCPListener: %T", listener)
+	}
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			close(accepted)
+			return
+		}
+		accepted <- conn
+	}()
+
+	client, err := Dial(context.TODO(), "tcp", listener.Addr().String(), cfg)
+	if err != nil {
+		t.Fatalf("dial err: %+v", err)
+	}
+	defer client.Close()
+	server, ok := <-accepted
+	if ok != true {
+		t.Fatalf("accept failed")
+	}
+	defer server.Close()
+
+	mark, err := GetMark(client)
+	if err != nil {
+		t.Fatalf("mark get err: %+v", err)
+	}
+	if mark != 0x100 {
+		t.Errorf("mark 0x100, actual:%#x", mark)
+	}
+	priority, err := GetPriority(client)
+	if err != nil {
+		t.Fatalf("priority get err: %+v", err)
+	}
+	if priority != 3 {
+		t.Errorf("priority 3, actual:%d", priority)
+	}
+	// negotiated in SYN, only effective when set before connect
+	info, err := GetMSS(client)
+	if err != nil {
+		t.Fatalf("mss get err: %+v", err)
+	}
+	if 1000 < info.SndMSS {
+		t.Errorf("snd_mss must be <= 1000, actual:%d", info.SndMSS)
+	}
+	// inherited from listener
+	if mark, _ := GetMark(server); mark != 0x100 {
+		t.Errorf("accepted conn mark 0x100, actual:%#x", mark)
+	}
+}
@@ -0,0 +1,63 @@
+package tcpoption
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"syscall"
+)
+
+const (
+	MD5KeyMaxLen int = 80 // TCP_MD5SIG_MAXKEYLEN
+)
+
+// MD5Key is RFC 2385 signature key for peers matching Peer
+type MD5Key struct {
+	Peer    netip.Prefix
+	Key     []byte
+	Ifindex int // VRF (l3mdev) device index the key is bound to, 0 matches any
+}
+
+// SetMD5Key installs key for peer on a listener or conn (*net.TCPListener, *net.TCPConn).
+// a listener accepts only SYNs signed with the key of a matching peer, conns inherit the keys.
+// prefix shorter than the address or non-zero ifindex uses TCP_MD5SIG_EXT (4.13+).
+// for client side the key must be in place before SYN, use Dialer with Config.MD5Keys.
+// returns ENOPROTOOPT where TCP_MD5SIG is not supported, never leaves the conn unsigned silently.
+func SetMD5Key(c syscall.Conn, peer netip.Prefix, key []byte, ifindex int) error {
+	return getFd(c, func(fd int) error {
+		return SetMD5KeyFd(fd, peer, key, ifindex)
+	})
+}
+
+func SetMD5KeyFd(fd int, peer netip.Prefix, key []byte, ifindex int) error {
+	if len(key) < 1 {
+		return fmt.Errorf("md5 key is empty, use RemoveMD5Key to remove")
+	}
+	if MD5KeyMaxLen < len(key) {
+		return fmt.Errorf("md5 key length %d exceeds %d", len(key), MD5KeyMaxLen)
+	}
+	if peer.IsValid() != true {
+		return fmt.Errorf("invalid md5 peer: %s", peer)
+	}
+	if err := setsockoptMD5Key(fd, peer.Masked(), key, ifindex); err != nil {
+		if ifindex != 0 && errors.Is(err, syscall.EINVAL) {
+			return fmt.Errorf("md5 ifindex %d must be a VRF (l3mdev) device: %w", ifindex, err)
+		}
+		return err
+	}
+	return nil
+}
+
+// RemoveMD5Key removes the key installed for the same peer and ifindex
+func RemoveMD5Key(c syscall.Conn, peer netip.Prefix, ifindex int) error {
+	return getFd(c, func(fd int) error {
+		return RemoveMD5KeyFd(fd, peer, ifindex)
+	})
+}
+
+func RemoveMD5KeyFd(fd int, peer netip.Prefix, ifindex int) error {
+	if peer.IsValid() != true {
+		return fmt.Errorf("invalid md5 peer: %s", peer)
+	}
+	return setsockoptMD5Key(fd, peer.Masked(), nil, ifindex)
+}
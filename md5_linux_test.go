@@ -0,0 +1,127 @@
+package tcpoption
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func dialMD5(network, address string, keys []MD5Key) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	return Dial(ctx, network, address, Config{MD5Keys: keys})
+}
+
+func TestSetMD5Key(t *testing.T) {
+	secret := []byte("bgp-peer-secret")
+	for _, tc := range []struct {
+		network string
+		address string
+		peer    netip.Prefix
+	}{
+		{"tcp4", "127.0.0.1:0", netip.MustParsePrefix("127.0.0.1/32")},
+		{"tcp4", "127.0.0.1:0", netip.MustParsePrefix("127.0.0.0/8")},
+		{"tcp6", "[::1]:0", netip.MustParsePrefix("::1/128")},
+		{"tcp", "[::]:0", netip.MustParsePrefix("127.0.0.0/8")}, // v4-mapped peer on dual-stack listener
+	} {
+		listener, err := Listen(context.TODO(), tc.network, tc.address, Config{
+			MD5Keys: []MD5Key{{Peer: tc.peer, Key: secret}},
+		})
+		if err != nil {
+			t.Skipf("%s listen err: %+v", tc.network, err)
+		}
+		accepted := acceptLoop(t, listener)
+		_, port, _ := net.SplitHostPort(listener.Addr().String())
+		peer := tc.peer.Addr()
+		if peer.Is4() {
+			peer = netip.MustParseAddr("127.0.0.1")
+		}
+		address := net.JoinHostPort(peer.String(), port)
+
+		t.Run(tc.peer.String()+"/match", func(tt *testing.T) {
+			client, err := dialMD5("tcp", address, []MD5Key{{Peer: netip.PrefixFrom(peer, peer.BitLen()), Key: secret}})
+			if err != nil {
+				tt.Fatalf("signed dial err: %+v", err)
+			}
+			defer client.Close()
+			server := <-accepted
+			defer server.Close()
+
+			if _, err := client.Write([]byte("open")); err != nil {
+				tt.Fatalf("write err: %+v", err)
+			}
+			buf := make([]byte, 4)
+			server.SetReadDeadline(time.Now().Add(time.Second))
+			if _, err := server.Read(buf); err != nil {
+				tt.Fatalf("read err: %+v", err)
+			}
+		})
+		t.Run(tc.peer.String()+"/mismatch", func(tt *testing.T) {
+			client, err := dialMD5("tcp", address, []MD5Key{{Peer: netip.PrefixFrom(peer, peer.BitLen()), Key: []byte("wrong")}})
+			if err == nil {
+				client.Close()
+				tt.Fatalf("mismatched key must not connect")
+			}
+		})
+		t.Run(tc.peer.String()+"/unsigned", func(tt *testing.T) {
+			client, err := dialMD5("tcp", address, nil)
+			if err == nil {
+				client.Close()
+				tt.Fatalf("unsigned SYN must not connect")
+			}
+		})
+		t.Run(tc.peer.String()+"/remove", func(tt *testing.T) {
+			if err := RemoveMD5Key(listener.(*net.TCPListener), tc.peer, 0); err != nil {
+				tt.Fatalf("remove err: %+v", err)
+			}
+			client, err := dialMD5("tcp", address, nil)
+			if err != nil {
+				tt.Fatalf("dial after remove err: %+v", err)
+			}
+			client.Close()
+			server := <-accepted
+			server.Close()
+		})
+		listener.Close()
+	}
+}
+
+func TestSetMD5KeyIfindex(t *testing.T) {
+	client, server := setupConnPair(t)
+	defer client.Close()
+	defer server.Close()
+
+	// only VRF (l3mdev) devices are accepted, loopback is not
+	lo := loopbackInterface(t)
+	err := SetMD5Key(client.(*net.TCPConn), netip.MustParsePrefix("127.0.0.1/32"), []byte("vrf-secret"), lo.Index)
+	if err == nil {
+		t.Fatalf("non VRF ifindex must fail")
+	}
+	if errors.Is(err, syscall.EINVAL) != true {
+		t.Errorf("expect EINVAL, actual:%+v", err)
+	}
+}
+
+func TestSetMD5KeyValidate(t *testing.T) {
+	client, server := setupConnPair(t)
+	defer client.Close()
+	defer server.Close()
+
+	c := client.(*net.TCPConn)
+	if err := SetMD5Key(c, netip.MustParsePrefix("127.0.0.1/32"), nil, 0); err == nil {
+		t.Errorf("empty key must fail")
+	}
+	if err := SetMD5Key(c, netip.MustParsePrefix("127.0.0.1/32"), make([]byte, MD5KeyMaxLen+1), 0); err == nil {
+		t.Errorf("too long key must fail")
+	}
+	if err := SetMD5Key(c, netip.Prefix{}, []byte("k"), 0); err == nil {
+		t.Errorf("invalid peer must fail")
+	}
+	if err := SetMD5Key(c, netip.MustParsePrefix("::1/128"), []byte("k"), 0); err == nil {
+		t.Errorf("ipv6 peer on ipv4 socket must fail")
+	}
+}
@@ -0,0 +1,146 @@
+package tcpoption
+
+import (
+	"fmt"
+	"net"
+)
+
+const (
+	MinMaxSeg        int = 88 // TCP_MIN_MSS
+	ipv4TCPHeaderLen int = 20 + 20
+	ipv6TCPHeaderLen int = 40 + 20
+)
+
+// SetMaxSeg sets user MSS of conn, it is negotiated in SYN so set it before connect(SetMaxSegFd)
+func SetMaxSeg(conn net.Conn, mss int) error {
+	if c, ok := conn.(*net.TCPConn); ok {
+		return getFd(c, func(fd int) error {
+			return setsockoptMaxSeg(fd, mss)
+		})
+	}
+	return nil
+}
+
+// SetListenerMaxSeg clamps MSS advertised in SYN-ACK of connections accepted by listener.
+func SetListenerMaxSeg(listener net.Listener, mss int) error {
+	if l, ok := listener.(*net.TCPListener); ok {
+		return getFd(l, func(fd int) error {
+			return setsockoptMaxSeg(fd, mss)
+		})
+	}
+	return nil
+}
+
+func SetMaxSegFd(fd int, mss int) error {
+	return setsockoptMaxSeg(fd, mss)
+}
+
+func GetMaxSeg(conn net.Conn) (int, error) {
+	if c, ok := conn.(*net.TCPConn); ok {
+		mss := 0
+		if err := getFd(c, func(fd int) error {
+			v, err := getsockoptMaxSeg(fd)
+			if err != nil {
+				return err
+			}
+			mss = v
+			return nil
+		}); err != nil {
+			return 0, err
+		}
+		return mss, nil
+	}
+	return 0, nil
+}
+
+func GetMaxSegFd(fd int) (int, error) {
+	return getsockoptMaxSeg(fd)
+}
+
+// SetWindowClamp caps the advertised receive window of conn.
+func SetWindowClamp(conn net.Conn, bytes int) error {
+	if c, ok := conn.(*net.TCPConn); ok {
+		return getFd(c, func(fd int) error {
+			return setsockoptWindowClamp(fd, bytes)
+		})
+	}
+	return nil
+}
+
+func SetWindowClampFd(fd int, bytes int) error {
+	return setsockoptWindowClamp(fd, bytes)
+}
+
+func GetWindowClamp(conn net.Conn) (int, error) {
+	if c, ok := conn.(*net.TCPConn); ok {
+		bytes := 0
+		if err := getFd(c, func(fd int) error {
+			v, err := getsockoptWindowClamp(fd)
+			if err != nil {
+				return err
+			}
+			bytes = v
+			return nil
+		}); err != nil {
+			return 0, err
+		}
+		return bytes, nil
+	}
+	return 0, nil
+}
+
+func GetWindowClampFd(fd int) (int, error) {
+	return getsockoptWindowClamp(fd)
+}
+
+type MSSInfo struct {
+	SndMSS int // MSS used for sending
+	RcvMSS int // MSS estimated from received segments
+	AdvMSS int // MSS advertised to peer
+}
+
+// GetMSS reads the MSS actually negotiated on conn from TCP_INFO
+func GetMSS(conn net.Conn) (MSSInfo, error) {
+	if c, ok := conn.(*net.TCPConn); ok {
+		info := MSSInfo{}
+		if err := getFd(c, func(fd int) error {
+			v, err := getsockoptMSSInfo(fd)
+			if err != nil {
+				return err
+			}
+			info = v
+			return nil
+		}); err != nil {
+			return MSSInfo{}, err
+		}
+		return info, nil
+	}
+	return MSSInfo{}, nil
+}
+
+// MaxSegForMTU returns the largest MSS fits in mtu without fragmentation
+func MaxSegForMTU(mtu int, ipv6 bool) int {
+	if ipv6 {
+		return mtu - ipv6TCPHeaderLen
+	}
+	return mtu - ipv4TCPHeaderLen
+}
+
+// ValidateMaxSeg checks mss against MTU of the interface named ifname
+func ValidateMaxSeg(mss int, ifname string, ipv6 bool) error {
+	ifi, err := net.InterfaceByName(ifname)
+	if err != nil {
+		return err
+	}
+	return validateMaxSeg(mss, ifi.MTU, ipv6)
+}
+
+func validateMaxSeg(mss int, mtu int, ipv6 bool) error {
+	if mss < MinMaxSeg {
+		return fmt.Errorf("mss %d is smaller than minimum %d", mss, MinMaxSeg)
+	}
+	if max := MaxSegForMTU(mtu, ipv6); max < mss {
+		return fmt.Errorf("mss %d exceeds %d allowed by mtu %d", mss, max, mtu)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package tcpoption
+
+import (
+	"net"
+	"syscall"
+	"testing"
+)
+
+func TestSetListenerMaxSeg(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen err: %+v", err)
+	}
+	defer listener.Close()
+
+	if err := SetListenerMaxSeg(listener, 1200); err != nil {
+		t.Fatalf("maxseg set err: %+v", err)
+	}
+
+	client, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("client open err: %+v", err)
+	}
+	defer client.Close()
+
+	server, err := listener.Accept()
+	if err != nil {
+		t.Fatalf("accept err: %+v", err)
+	}
+	defer server.Close()
+
+	serverMSS, err := GetMSS(server)
+	if err != nil {
+		t.Fatalf("server mss err: %+v", err)
+	}
+	if serverMSS.AdvMSS == 0 || 1200 < serverMSS.AdvMSS {
+		t.Errorf("server must advertise clamped mss: %+v", serverMSS)
+	}
+	clientMSS, err := GetMSS(client)
+	if err != nil {
+		t.Fatalf("client mss err: %+v", err)
+	}
+	if clientMSS.SndMSS == 0 || 1200 < clientMSS.SndMSS {
+		t.Errorf("client must send with clamped mss: %+v", clientMSS)
+	}
+}
+
+func TestSetMaxSegFd(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen err: %+v", err)
+	}
+	defer listener.Close()
+
+	d := &net.Dialer{
+		Control: func(network, address string, c syscall.RawConn) error {
+			var fdErr error
+			if err := c.Control(func(fd uintptr) {
+				fdErr = SetMaxSegFd(int(fd), 1000)
+			}); err != nil {
+				return err
+			}
+			return fdErr
+		},
+	}
+	client, err := d.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("client open err: %+v", err)
+	}
+	defer client.Close()
+
+	server, err := listener.Accept()
+	if err != nil {
+		t.Fatalf("accept err: %+v", err)
+	}
+	defer server.Close()
+
+	mss, err := GetMaxSeg(client)
+	if err != nil {
+		t.Fatalf("maxseg get err: %+v", err)
+	}
+	if mss == 0 || 1000 < mss {
+		t.Errorf("maxseg must be clamped: %d", mss)
+	}
+	serverMSS, err := GetMSS(server)
+	if err != nil {
+		t.Fatalf("server mss err: %+v", err)
+	}
+	if serverMSS.SndMSS == 0 || 1000 < serverMSS.SndMSS {
+		t.Errorf("server must send with mss advertised by client: %+v", serverMSS)
+	}
+}
+
+func TestSetWindowClamp(t *testing.T) {
+	client, server := setupConnPair(t)
+	defer client.Close()
+	defer server.Close()
+
+	if err := SetWindowClamp(client, 64*1024); err != nil {
+		t.Fatalf("window_clamp set err: %+v", err)
+	}
+	v, err := GetWindowClamp(client)
+	if err != nil {
+		t.Fatalf("window_clamp get err: %+v", err)
+	}
+	if v != 64*1024 {
+		t.Errorf("window_clamp 64KiB, actual:%d", v)
+	}
+}
@@ -0,0 +1,194 @@
+package tcpoption
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	UnlimitedPacingRate uint64 = ^uint64(0)
+)
+
+// SetMaxPacingRate caps the transmit rate of conn in bytes per second, pacing is done by kernel.
+func SetMaxPacingRate(conn net.Conn, bytesPerSec uint64) error {
+	if c, ok := conn.(*net.TCPConn); ok {
+		return getFd(c, func(fd int) error {
+			return setsockoptMaxPacingRate(fd, bytesPerSec)
+		})
+	}
+	return nil
+}
+
+func SetMaxPacingRateFd(fd int, bytesPerSec uint64) error {
+	return setsockoptMaxPacingRate(fd, bytesPerSec)
+}
+
+func GetMaxPacingRate(conn net.Conn) (uint64, error) {
+	if c, ok := conn.(*net.TCPConn); ok {
+		rate := uint64(0)
+		if err := getFd(c, func(fd int) error {
+			v, err := getsockoptMaxPacingRate(fd)
+			if err != nil {
+				return err
+			}
+			rate = v
+			return nil
+		}); err != nil {
+			return 0, err
+		}
+		return rate, nil
+	}
+	return 0, nil
+}
+
+func GetMaxPacingRateFd(fd int) (uint64, error) {
+	return getsockoptMaxPacingRate(fd)
+}
+
+// GetPacingRate returns the pacing rate currently used by kernel (tcpi_pacing_rate)
+func GetPacingRate(conn net.Conn) (uint64, error) {
+	if c, ok := conn.(*net.TCPConn); ok {
+		rate := uint64(0)
+		if err := getFd(c, func(fd int) error {
+			v, err := getsockoptPacingRate(fd)
+			if err != nil {
+				return err
+			}
+			rate = v
+			return nil
+		}); err != nil {
+			return 0, err
+		}
+		return rate, nil
+	}
+	return 0, nil
+}
+
+type PacingWindow struct {
+	From time.Duration // offset from midnight
+	To   time.Duration
+	Rate uint64
+}
+
+// PacingSchedule selects a rate by local time of day, e.g. unlimited off-peak and throttled at peak.
+type PacingSchedule struct {
+	Windows []PacingWindow
+	Default uint64
+}
+
+func (s PacingSchedule) RateAt(t time.Time) uint64 {
+	y, m, d := t.Date()
+	offset := t.Sub(time.Date(y, m, d, 0, 0, 0, 0, t.Location()))
+	for _, w := range s.Windows {
+		if w.From <= w.To {
+			if w.From <= offset && offset < w.To {
+				return w.Rate
+			}
+			continue
+		}
+		// window across midnight
+		if w.From <= offset || offset < w.To {
+			return w.Rate
+		}
+	}
+	return s.Default
+}
+
+// PacedConn is a conn whose max pacing rate can be changed at runtime.
+type PacedConn struct {
+	net.Conn
+
+	rate  uint64
+	mutex sync.Mutex
+	done  chan struct{}
+	wg    sync.WaitGroup
+}
+
+func NewPacedConn(conn net.Conn, bytesPerSec uint64) (*PacedConn, error) {
+	c := &PacedConn{Conn: conn}
+	if err := c.SetRate(bytesPerSec); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *PacedConn) SetRate(bytesPerSec uint64) error {
+	if err := SetMaxPacingRate(c.Conn, bytesPerSec); err != nil {
+		return err
+	}
+	atomic.StoreUint64(&c.rate, bytesPerSec)
+	return nil
+}
+
+func (c *PacedConn) Rate() uint64 {
+	return atomic.LoadUint64(&c.rate)
+}
+
+func (c *PacedConn) PacingRate() (uint64, error) {
+	return GetPacingRate(c.Conn)
+}
+
+// StartSchedule applies s now and re-evaluates it every interval until StopSchedule or Close
+func (c *PacedConn) StartSchedule(s PacingSchedule, interval time.Duration, onError func(error)) error {
+	if interval <= 0 {
+		return fmt.Errorf("schedule interval must be positive: %s", interval)
+	}
+	if err := c.SetRate(s.RateAt(time.Now())); err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.done != nil {
+		return nil
+	}
+	done := make(chan struct{})
+	c.done = done
+	c.wg.Add(1)
+	go c.runSchedule(done, s, interval, onError)
+	return nil
+}
+
+func (c *PacedConn) StopSchedule() {
+	c.mutex.Lock()
+	if c.done != nil {
+		close(c.done)
+		c.done = nil
+	}
+	c.mutex.Unlock()
+
+	c.wg.Wait()
+}
+
+func (c *PacedConn) Close() error {
+	c.StopSchedule()
+	return c.Conn.Close()
+}
+
+func (c *PacedConn) runSchedule(done chan struct{}, s PacingSchedule, interval time.Duration, onError func(error)) {
+	defer c.wg.Done()
+
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case t := <-tick.C:
+			rate := s.RateAt(t)
+			if rate == c.Rate() {
+				continue
+			}
+			if err := c.SetRate(rate); err != nil {
+				if onError != nil {
+					onError(err)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package tcpoption
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func measureTransfer(t *testing.T, client, server net.Conn, size int) time.Duration {
+	done := make(chan int64)
+	go func() {
+		n, _ := io.CopyN(io.Discard, server, int64(size))
+		done <- n
+	}()
+
+	start := time.Now()
+	if _, err := client.Write(make([]byte, size)); err != nil {
+		t.Fatalf("write err: %+v", err)
+	}
+	if n := <-done; n != int64(size) {
+		t.Fatalf("read %d/%d", n, size)
+	}
+	return time.Since(start)
+}
+
+func TestSetMaxPacingRate(t *testing.T) {
+	client, server := setupConnPair(t)
+	defer client.Close()
+	defer server.Close()
+
+	if err := SetMaxPacingRate(client, 1024*1024); err != nil {
+		t.Fatalf("max_pacing_rate set err: %+v", err)
+	}
+	v, err := GetMaxPacingRate(client)
+	if err != nil {
+		t.Fatalf("max_pacing_rate get err: %+v", err)
+	}
+	if v != 1024*1024 {
+		t.Errorf("max_pacing_rate 1MiB/s, actual:%d", v)
+	}
+}
+
+func TestPacedConn(t *testing.T) {
+	size := 2 * 1024 * 1024
+
+	t.Run("unlimited", func(tt *testing.T) {
+		client, server := setupConnPair(tt)
+		defer client.Close()
+		defer server.Close()
+
+		elapsed := measureTransfer(tt, client, server, size)
+		tt.Logf("unlimited: %s", elapsed)
+		if 500*time.Millisecond < elapsed {
+			tt.Errorf("loopback without pacing must be fast: %s", elapsed)
+		}
+	})
+	t.Run("paced", func(tt *testing.T) {
+		client, server := setupConnPair(tt)
+		defer client.Close()
+		defer server.Close()
+
+		c, err := NewPacedConn(client, 2*1024*1024)
+		if err != nil {
+			tt.Fatalf("paced conn err: %+v", err)
+		}
+		elapsed := measureTransfer(tt, c, server, size)
+		tt.Logf("paced 2MiB/s: %s", elapsed)
+		if elapsed < 500*time.Millisecond {
+			tt.Errorf("2MiB at 2MiB/s must be throttled: %s", elapsed)
+		}
+
+		rate, err := c.PacingRate()
+		if err != nil {
+			tt.Fatalf("pacing rate err: %+v", err)
+		}
+		if rate == 0 || 2*1024*1024 < rate {
+			tt.Errorf("pacing rate must be capped: %d", rate)
+		}
+
+		if err := c.SetRate(UnlimitedPacingRate); err != nil {
+			tt.Fatalf("set rate err: %+v", err)
+		}
+		elapsed = measureTransfer(tt, c, server, size)
+		tt.Logf("unlimited again: %s", elapsed)
+		if 500*time.Millisecond < elapsed {
+			tt.Errorf("runtime rate change must take effect: %s", elapsed)
+		}
+	})
+}
+
+func TestPacedConnSchedule(t *testing.T) {
+	client, server := setupConnPair(t)
+	defer client.Close()
+	defer server.Close()
+
+	c, err := NewPacedConn(client, UnlimitedPacingRate)
+	if err != nil {
+		t.Fatalf("paced conn err: %+v", err)
+	}
+	defer c.Close()
+
+	s := PacingSchedule{
+		Windows: []PacingWindow{
+			{From: 0, To: 24 * time.Hour, Rate: 512 * 1024},
+		},
+		Default: UnlimitedPacingRate,
+	}
+	if err := c.StartSchedule(s, 10*time.Millisecond, func(err error) {
+		t.Errorf("schedule err: %+v", err)
+	}); err != nil {
+		t.Fatalf("start schedule err: %+v", err)
+	}
+	if v, err := GetMaxPacingRate(c.Conn); err != nil || v != 512*1024 {
+		t.Errorf("schedule must apply window rate: %d %+v", v, err)
+	}
+	c.StopSchedule()
+
+	if err := c.StartSchedule(s, 0, nil); err == nil {
+		t.Errorf("zero interval must be rejected")
+	}
+}
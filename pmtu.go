@@ -0,0 +1,202 @@
+package tcpoption
+
+import (
+	"fmt"
+	"net"
+)
+
+// IP_MTU_DISCOVER / IPV6_MTU_DISCOVER mode, kernel value is mode-1 so zero Config leaves the sysctl default
+type PMTUDiscMode uint8
+
+const (
+	PMTUDiscDefault   PMTUDiscMode = iota
+	PMTUDiscDont                   // never send DF, fragment locally
+	PMTUDiscWant                   // DF per route, fragment if needed
+	PMTUDiscDo                     // always DF
+	PMTUDiscProbe                  // DF, ignore PMTU
+	PMTUDiscInterface              // use interface MTU, ignore ICMP frag needed
+	PMTUDiscOmit                   // like interface, but DF is not set
+)
+
+var pmtuDiscNames = map[PMTUDiscMode]string{
+	PMTUDiscDefault:   "default",
+	PMTUDiscDont:      "dont",
+	PMTUDiscWant:      "want",
+	PMTUDiscDo:        "do",
+	PMTUDiscProbe:     "probe",
+	PMTUDiscInterface: "interface",
+	PMTUDiscOmit:      "omit",
+}
+
+func (m PMTUDiscMode) String() string {
+	if name, ok := pmtuDiscNames[m]; ok {
+		return name
+	}
+	return fmt.Sprintf("PMTUDiscMode(%d)", m)
+}
+
+// SetMTUDiscover sets IP_MTU_DISCOVER or IPV6_MTU_DISCOVER by socket family
+func SetMTUDiscover(conn net.Conn, mode PMTUDiscMode) error {
+	if c, ok := conn.(*net.TCPConn); ok {
+		return getFd(c, func(fd int) error {
+			return SetMTUDiscoverFd(fd, mode)
+		})
+	}
+	return nil
+}
+
+func SetMTUDiscoverFd(fd int, mode PMTUDiscMode) error {
+	if mode == PMTUDiscDefault || PMTUDiscOmit < mode {
+		return fmt.Errorf("invalid pmtu discover mode: %s", mode)
+	}
+	ipv4, ipv6, err := tosFamily(fd)
+	if err != nil {
+		return err
+	}
+	if ipv6 {
+		if err := setsockoptIPv6MTUDiscover(fd, int(mode)-1); err != nil {
+			return err
+		}
+	}
+	if ipv4 {
+		if err := setsockoptMTUDiscover(fd, int(mode)-1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func GetMTUDiscover(conn net.Conn) (PMTUDiscMode, error) {
+	if c, ok := conn.(*net.TCPConn); ok {
+		mode := PMTUDiscDefault
+		if err := getFd(c, func(fd int) error {
+			v, err := GetMTUDiscoverFd(fd)
+			if err != nil {
+				return err
+			}
+			mode = v
+			return nil
+		}); err != nil {
+			return PMTUDiscDefault, err
+		}
+		return mode, nil
+	}
+	return PMTUDiscDefault, nil
+}
+
+func GetMTUDiscoverFd(fd int) (PMTUDiscMode, error) {
+	ipv4, _, err := tosFamily(fd)
+	if err != nil {
+		return PMTUDiscDefault, err
+	}
+	v := 0
+	if ipv4 {
+		v, err = getsockoptMTUDiscover(fd)
+	} else {
+		v, err = getsockoptIPv6MTUDiscover(fd)
+	}
+	if err != nil {
+		return PMTUDiscDefault, err
+	}
+	return PMTUDiscMode(v + 1), nil
+}
+
+// PathMTU returns the path MTU cached for the destination of conn (IP_MTU / IPV6_MTU)
+func PathMTU(conn net.Conn) (int, error) {
+	if c, ok := conn.(*net.TCPConn); ok {
+		mtu := 0
+		if err := getFd(c, func(fd int) error {
+			v, err := PathMTUFd(fd)
+			if err != nil {
+				return err
+			}
+			mtu = v
+			return nil
+		}); err != nil {
+			return 0, err
+		}
+		return mtu, nil
+	}
+	return 0, nil
+}
+
+// PathMTUFd requires a connected socket
+func PathMTUFd(fd int) (int, error) {
+	ipv4, _, err := tosFamily(fd)
+	if err != nil {
+		return 0, err
+	}
+	if ipv4 {
+		return getsockoptMTU(fd)
+	}
+	return getsockoptIPv6MTU(fd)
+}
+
+const (
+	maxIPPacket int = 65535 // IP_MAX_MTU, path MTU never exceeds it even on 64k loopback
+)
+
+type PathMTUStatus struct {
+	PathMTU      int // tcpi_pmtu
+	Interface    string
+	InterfaceMTU int
+	BlackHole    bool // path MTU is lower than interface MTU, large segments may be dropped silently
+}
+
+// CheckPathMTU compares TCP_INFO pmtu with the MTU of the interface owning the local address of conn.
+// a lower pmtu means ICMP frag needed was received (or probed), when it keeps dropping
+// while segments are retransmitted the path is a likely PMTU black hole.
+func CheckPathMTU(conn net.Conn) (PathMTUStatus, error) {
+	c, ok := conn.(*net.TCPConn)
+	if ok != true {
+		return PathMTUStatus{}, nil
+	}
+	pmtu := 0
+	if err := getFd(c, func(fd int) error {
+		v, err := getsockoptTCPInfoPMTU(fd)
+		if err != nil {
+			return err
+		}
+		pmtu = v
+		return nil
+	}); err != nil {
+		return PathMTUStatus{}, err
+	}
+
+	ifi, err := interfaceByAddr(c.LocalAddr().(*net.TCPAddr).IP)
+	if err != nil {
+		return PathMTUStatus{}, err
+	}
+	status := PathMTUStatus{
+		PathMTU:      pmtu,
+		Interface:    ifi.Name,
+		InterfaceMTU: ifi.MTU,
+	}
+	ifmtu := ifi.MTU
+	if maxIPPacket < ifmtu {
+		ifmtu = maxIPPacket
+	}
+	if 0 < pmtu && pmtu < ifmtu {
+		status.BlackHole = true
+	}
+	return status, nil
+}
+
+func interfaceByAddr(ip net.IP) (*net.Interface, error) {
+	ifs, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	for i := range ifs {
+		addrs, err := ifs[i].Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.Equal(ip) {
+				return &ifs[i], nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("no interface has address %s", ip)
+}
@@ -0,0 +1,60 @@
+package tcpoption
+
+import (
+	"testing"
+)
+
+func TestSetMTUDiscover(t *testing.T) {
+	client, server := setupConnPair(t)
+	defer client.Close()
+	defer server.Close()
+
+	for _, mode := range []PMTUDiscMode{PMTUDiscDont, PMTUDiscWant, PMTUDiscDo, PMTUDiscProbe, PMTUDiscInterface, PMTUDiscOmit} {
+		if err := SetMTUDiscover(client, mode); err != nil {
+			t.Fatalf("mtu_discover %s set err: %+v", mode, err)
+		}
+		v, err := GetMTUDiscover(client)
+		if err != nil {
+			t.Fatalf("mtu_discover get err: %+v", err)
+		}
+		if v != mode {
+			t.Errorf("expect %s, actual:%s", mode, v)
+		}
+	}
+	if err := SetMTUDiscover(client, PMTUDiscDefault); err == nil {
+		t.Errorf("default is not settable")
+	}
+}
+
+func TestPathMTU(t *testing.T) {
+	client, server := setupConnPair(t)
+	defer client.Close()
+	defer server.Close()
+
+	lo := loopbackInterface(t)
+	expect := lo.MTU
+	if maxIPPacket < expect {
+		expect = maxIPPacket
+	}
+	mtu, err := PathMTU(client)
+	if err != nil {
+		t.Fatalf("path mtu err: %+v", err)
+	}
+	if mtu != expect {
+		t.Errorf("loopback path mtu %d, actual:%d", expect, mtu)
+	}
+
+	status, err := CheckPathMTU(client)
+	if err != nil {
+		t.Fatalf("check path mtu err: %+v", err)
+	}
+	if status.Interface != lo.Name || status.InterfaceMTU != lo.MTU {
+		t.Errorf("expect %s mtu %d, actual:%+v", lo.Name, lo.MTU, status)
+	}
+	if status.PathMTU < 1 || lo.MTU < status.PathMTU {
+		t.Errorf("tcpi_pmtu must be 1-%d, actual:%d", lo.MTU, status.PathMTU)
+	}
+	if status.BlackHole {
+		t.Errorf("loopback must not be black hole: %+v", status)
+	}
+}
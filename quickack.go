@@ -0,0 +1,81 @@
+package tcpoption
+
+import (
+	"net"
+	"sync/atomic"
+	"syscall"
+)
+
+// QuickACKConn re-applies TCP_QUICKACK after every Read,
+// linux clears the flag on its own after a few segments.
+type QuickACKConn struct {
+	net.Conn
+
+	raw     syscall.RawConn
+	enabled int32
+	rearms  uint64
+}
+
+func NewQuickACKConn(conn net.Conn) (*QuickACKConn, error) {
+	c := &QuickACKConn{
+		Conn:    conn,
+		enabled: 1,
+	}
+	if tc, ok := conn.(*net.TCPConn); ok {
+		raw, err := tc.SyscallConn()
+		if err != nil {
+			return nil, err
+		}
+		c.raw = raw
+		if err := c.rearm(); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func (c *QuickACKConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if 0 < n && atomic.LoadInt32(&c.enabled) == 1 {
+		if e := c.rearm(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return n, err
+}
+
+// Enable re-arms TCP_QUICKACK on subsequent reads (e.g. request/response phase).
+func (c *QuickACKConn) Enable() error {
+	atomic.StoreInt32(&c.enabled, 1)
+	return c.rearm()
+}
+
+// Disable stops re-arming (e.g. bulk transfer phase), delayed ACK takes over again.
+func (c *QuickACKConn) Disable() {
+	atomic.StoreInt32(&c.enabled, 0)
+}
+
+func (c *QuickACKConn) Enabled() bool {
+	return atomic.LoadInt32(&c.enabled) == 1
+}
+
+func (c *QuickACKConn) Rearms() uint64 {
+	return atomic.LoadUint64(&c.rearms)
+}
+
+func (c *QuickACKConn) rearm() error {
+	if c.raw == nil {
+		return nil
+	}
+	var fdErr error
+	if err := c.raw.Control(func(fd uintptr) {
+		fdErr = setsockoptQuickACK(int(fd), 1)
+	}); err != nil {
+		return err
+	}
+	if fdErr != nil {
+		return fdErr
+	}
+	atomic.AddUint64(&c.rearms, 1)
+	return nil
+}
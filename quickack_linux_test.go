@@ -0,0 +1,64 @@
+package tcpoption
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestQuickACKConn(t *testing.T) {
+	client, server := setupConnPair(t)
+	defer client.Close()
+	defer server.Close()
+
+	c, err := NewQuickACKConn(client)
+	if err != nil {
+		t.Fatalf("wrap err: %+v", err)
+	}
+	if v := c.Rearms(); v != 1 {
+		t.Errorf("initial arm: %d", v)
+	}
+
+	buf := make([]byte, 4)
+	for i := 0; i < 3; i += 1 {
+		server.Write([]byte("PING"))
+		c.SetReadDeadline(time.Now().Add(time.Second))
+		if _, err := io.ReadFull(c, buf); err != nil {
+			t.Fatalf("read err: %+v", err)
+		}
+	}
+	if v := c.Rearms(); v < 4 {
+		t.Errorf("rearm after each read: %d", v)
+	}
+	if err := getFd(client.(*net.TCPConn), func(fd int) error {
+		if v, err := getsockoptQuickACK(fd); err != nil {
+			t.Errorf("quickack get err: %+v", err)
+		} else {
+			if v != 1 {
+				t.Errorf("quickack must be armed: %d", v)
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Errorf("must no error: %+v", err)
+	}
+
+	c.Disable()
+	before := c.Rearms()
+	server.Write([]byte("PING"))
+	c.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := io.ReadFull(c, buf); err != nil {
+		t.Fatalf("read err: %+v", err)
+	}
+	if v := c.Rearms(); v != before {
+		t.Errorf("disabled conn must not rearm: %d -> %d", before, v)
+	}
+
+	if err := c.Enable(); err != nil {
+		t.Errorf("enable err: %+v", err)
+	}
+	if v := c.Rearms(); v != before+1 {
+		t.Errorf("enable must rearm: %d", v)
+	}
+}
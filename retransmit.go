@@ -0,0 +1,113 @@
+package tcpoption
+
+import (
+	"net"
+	"time"
+)
+
+// include/net/tcp.h
+const (
+	DefaultSynRetries  int           = 6  // net.ipv4.tcp_syn_retries
+	DefaultRetries2    int           = 15 // net.ipv4.tcp_retries2
+	MaxSynRetries      int           = 127
+	tcpTimeoutInit     time.Duration = 1 * time.Second
+	tcpRTOMin          time.Duration = 200 * time.Millisecond
+	tcpRTOMax          time.Duration = 120 * time.Second
+	tcpThinLinearRetry int           = 6
+)
+
+// RetransmitPolicy bounds how long handshake and retransmissions keep going.
+// zero value leaves the kernel defaults.
+type RetransmitPolicy struct {
+	SynRetries         int           // TCP_SYNCNT, SYN retransmissions before connect fails
+	ThinLinearTimeouts bool          // TCP_THIN_LINEAR_TIMEOUTS, no RTO backoff for thin streams
+	FinWait2Timeout    time.Duration // TCP_LINGER2, lifetime of orphaned FIN_WAIT2
+}
+
+// ConnectTimeout returns the worst-case time connect(2) takes to fail when peer never answers
+func (p RetransmitPolicy) ConnectTimeout() time.Duration {
+	retries := p.SynRetries
+	if retries <= 0 {
+		retries = DefaultSynRetries
+	}
+	return modelTimeout(retries, tcpTimeoutInit)
+}
+
+// DeadPeerTimeout returns the worst-case time until an unacknowledged transmission is given up
+// with tcp_retries2 default, RTO starts from TCP_RTO_MIN as the kernel models it.
+// tcp_retries2 is enforced as elapsed time of this model, so RetransmitPolicy
+// (TCP_THIN_LINEAR_TIMEOUTS retransmits more often within it) does not change the bound.
+func DeadPeerTimeout() time.Duration {
+	return modelTimeout(DefaultRetries2, tcpRTOMin)
+}
+
+// tcp_model_timeout: exponential backoff from rtoBase until TCP_RTO_MAX, linear after that
+func modelTimeout(retries int, rtoBase time.Duration) time.Duration {
+	thresh := 0
+	for (rtoBase << (thresh + 1)) <= tcpRTOMax {
+		thresh += 1
+	}
+	if retries <= thresh {
+		return time.Duration((2<<retries)-1) * rtoBase
+	}
+	return time.Duration((2<<thresh)-1)*rtoBase + time.Duration(retries-thresh)*tcpRTOMax
+}
+
+func SetRetransmitPolicy(conn net.Conn, p RetransmitPolicy) error {
+	if c, ok := conn.(*net.TCPConn); ok {
+		return getFd(c, func(fd int) error {
+			return setRetransmitPolicy(fd, p)
+		})
+	}
+	return nil
+}
+
+// SetRetransmitPolicyFd applies p before connect, TCP_SYNCNT has no effect after handshake
+func SetRetransmitPolicyFd(fd int, p RetransmitPolicy) error {
+	return setRetransmitPolicy(fd, p)
+}
+
+func SetSynCount(conn net.Conn, count int) error {
+	if c, ok := conn.(*net.TCPConn); ok {
+		return getFd(c, func(fd int) error {
+			return setsockoptSynCount(fd, count)
+		})
+	}
+	return nil
+}
+
+func SetSynCountFd(fd int, count int) error {
+	return setsockoptSynCount(fd, count)
+}
+
+func SetThinLinearTimeouts(conn net.Conn, enable bool) error {
+	if c, ok := conn.(*net.TCPConn); ok {
+		return getFd(c, func(fd int) error {
+			return setsockoptThinLinearTimeouts(fd, IntBool(enable))
+		})
+	}
+	return nil
+}
+
+func SetThinLinearTimeoutsFd(fd int, enable bool) error {
+	return setsockoptThinLinearTimeouts(fd, IntBool(enable))
+}
+
+func setRetransmitPolicy(fd int, p RetransmitPolicy) error {
+	if 0 < p.SynRetries {
+		if err := setsockoptSynCount(fd, p.SynRetries); err != nil {
+			return err
+		}
+	}
+	if p.ThinLinearTimeouts {
+		if err := setsockoptThinLinearTimeouts(fd, 1); err != nil {
+			return err
+		}
+	}
+	if 0 < p.FinWait2Timeout {
+		if err := setsockoptLingerTimeout(fd, p.FinWait2Timeout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
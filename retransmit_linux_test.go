@@ -0,0 +1,107 @@
+package tcpoption
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestSetRetransmitPolicy(t *testing.T) {
+	client, server := setupConnPair(t)
+	defer client.Close()
+	defer server.Close()
+
+	p := RetransmitPolicy{
+		SynRetries:         2,
+		ThinLinearTimeouts: true,
+		FinWait2Timeout:    7 * time.Second,
+	}
+	if err := Set(client, Config{EnableNoDelay: true, Retransmit: p}); err != nil {
+		t.Fatalf("set err: %+v", err)
+	}
+	if err := getFd(client.(*net.TCPConn), func(fd int) error {
+		if v, err := getsockoptSynCount(fd); err != nil {
+			t.Errorf("syncnt get err: %+v", err)
+		} else {
+			if v != 2 {
+				t.Errorf("syncnt 2, actual:%d", v)
+			}
+		}
+		if v, err := getsockoptThinLinearTimeouts(fd); err != nil {
+			t.Errorf("thin_linear_timeouts get err: %+v", err)
+		} else {
+			if v != 1 {
+				t.Errorf("enable thin_linear_timeouts: %d", v)
+			}
+		}
+		if v, err := getsockoptLingerTimeout(fd); err != nil {
+			t.Errorf("linger2 get err: %+v", err)
+		} else {
+			if v != 7 {
+				t.Errorf("linger2 7s, actual:%d", v)
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Errorf("must no error: %+v", err)
+	}
+}
+
+func TestRetransmitPolicySubSecondFinWait2(t *testing.T) {
+	client, server := setupConnPair(t)
+	defer client.Close()
+	defer server.Close()
+
+	if err := SetRetransmitPolicy(client, RetransmitPolicy{FinWait2Timeout: 300 * time.Millisecond}); err != nil {
+		t.Fatalf("set err: %+v", err)
+	}
+	if err := getFd(client.(*net.TCPConn), func(fd int) error {
+		v, err := getsockoptLingerTimeout(fd)
+		if err != nil {
+			return err
+		}
+		if v != 1 {
+			t.Errorf("300ms must round up to 1s, actual:%d", v)
+		}
+		return nil
+	}); err != nil {
+		t.Errorf("must no error: %+v", err)
+	}
+}
+
+func TestSetLingerTimeout(t *testing.T) {
+	client, server := setupConnPair(t)
+	defer client.Close()
+	defer server.Close()
+
+	if err := SetLingerTimeout(client, 15*time.Second); err != nil {
+		t.Fatalf("linger2 set err: %+v", err)
+	}
+	if err := getFd(client.(*net.TCPConn), func(fd int) error {
+		if v, err := getsockoptLingerTimeout(fd); err != nil {
+			t.Errorf("linger2 get err: %+v", err)
+		} else {
+			if v != 15 {
+				t.Errorf("linger2 15s, actual:%d", v)
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Errorf("must no error: %+v", err)
+	}
+	if err := SetLingerTimeout(client, 500*time.Millisecond); err != nil {
+		t.Fatalf("linger2 set err: %+v", err)
+	}
+	if err := getFd(client.(*net.TCPConn), func(fd int) error {
+		if v, err := getsockoptLingerTimeout(fd); err != nil {
+			t.Errorf("linger2 get err: %+v", err)
+		} else {
+			if v != 1 {
+				t.Errorf("500ms must round up to 1s, actual:%d", v)
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Errorf("must no error: %+v", err)
+	}
+}
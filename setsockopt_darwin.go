@@ -1,17 +1,25 @@
 package tcpoption
 
 import (
+	"net"
+	"net/netip"
 	"os"
 	"syscall"
 	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
 )
 
 // netinet/tcp.h
 const (
-	DARWIN_TCP_KEEPIDLE  int = 0x10
-	DARWIN_TCP_KEEPINTVL int = 0x101
-	DARWIN_TCP_KEEPCNT   int = 0x102
-	DARWIN_TCP_FASTOPEN  int = 0x105
+	DARWIN_TCP_NOPUSH           int = 0x04
+	DARWIN_TCP_KEEPIDLE         int = 0x10
+	DARWIN_TCP_RXT_CONNDROPTIME int = 0x80
+	DARWIN_TCP_KEEPINTVL        int = 0x101
+	DARWIN_TCP_KEEPCNT          int = 0x102
+	DARWIN_TCP_FASTOPEN         int = 0x105
+	DARWIN_TCP_NOTSENT_LOWAT    int = 0x201
 )
 
 // netinet/tcp_var.h
@@ -22,14 +30,31 @@ const (
 
 // sys/socket.h
 const (
-	DARWIN_SO_REUSEADDR int = 0x0004
-	DARWIN_SO_REUSEPORT int = 0x0200
+	DARWIN_SO_REUSEADDR    int = 0x0004
+	DARWIN_SO_REUSEPORT    int = 0x0200
+	DARWIN_SO_ACCEPTFILTER int = 0x1000
 )
 
+// netinet/in.h, netinet6/in6.h
+const (
+	DARWIN_IP_BOUND_IF   int = 0x19
+	DARWIN_IPV6_BOUND_IF int = 0x7d
+)
+
+// struct accept_filter_arg
+type acceptFilterArg struct {
+	Name [16]byte
+	Arg  [256 - 16]byte
+}
+
 func setsockoptLingerTimeout(fd int, d time.Duration) error {
 	return nil // no option by darwin
 }
 
+func getsockoptLingerTimeout(fd int) (int, error) {
+	return 0, nil // not support
+}
+
 func setsockoptKeepAliveIdle(fd int, sec int) error {
 	return os.NewSyscallError(
 		"setsockopt",
@@ -89,14 +114,79 @@ func getsockoptFastOpenConnect(fd int) (int, error) {
 	return 0, nil // not support
 }
 
-func setsockoptQuickACK(fd int, onoff int) error {
+func setsockoptFastOpenKey(fd int, keys []byte) error {
 	return nil // not support
 }
 
-func setsockoptDeferAccept(fd int, onoff int) error {
+func getsockoptFastOpenKey(fd int) ([]byte, error) {
+	return nil, nil // not support
+}
+
+func setsockoptFastOpenNoCookie(fd int, onoff int) error {
+	return nil // not support
+}
+
+func getsockoptFastOpenNoCookie(fd int) (int, error) {
+	return 0, nil // not support
+}
+
+func connectFastOpen(fd int, address string, first []byte) (int, error) {
+	return 0, nil // not support
+}
+
+func getsockoptFastOpenResult(fd int) (FastOpenResult, bool, error) {
+	return FastOpenResult{Fallback: true}, true, nil // not support
+}
+
+func setsockoptQuickACK(fd int, onoff int) error {
 	return nil // not support
 }
 
+func getsockoptQuickACK(fd int) (int, error) {
+	return 0, nil // not support
+}
+
+// SO_ACCEPTFILTER "dataready" has no timeout, sec only switches it on/off.
+// XNU accepts it on listening sockets only, conns and sockets before listen() are skipped
+func setsockoptDeferAccept(fd int, sec int) error {
+	listening, err := syscall.GetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_ACCEPTCONN)
+	if err != nil {
+		return os.NewSyscallError("getsockopt", err)
+	}
+	if listening == 0 {
+		return nil // not support before listen()
+	}
+	arg := ""
+	if 0 < sec {
+		filter := acceptFilterArg{}
+		copy(filter.Name[:], "dataready")
+		arg = string((*[unsafe.Sizeof(filter)]byte)(unsafe.Pointer(&filter))[:])
+	}
+	err = syscall.SetsockoptString(fd, syscall.SOL_SOCKET, DARWIN_SO_ACCEPTFILTER, arg)
+	if err == syscall.ENOPROTOOPT || err == syscall.ENOENT {
+		return nil // not support
+	}
+	if err == syscall.EINVAL && sec == 0 {
+		return nil // no filter installed
+	}
+	return os.NewSyscallError("setsockopt", err)
+}
+
+// "dataready" has no timeout, reports 1 second while the filter is installed
+func getsockoptDeferAccept(fd int) (int, error) {
+	name, err := unix.GetsockoptString(fd, syscall.SOL_SOCKET, DARWIN_SO_ACCEPTFILTER)
+	if err == syscall.EINVAL || err == syscall.ENOPROTOOPT {
+		return 0, nil // no filter installed or not listening
+	}
+	if err != nil {
+		return 0, os.NewSyscallError("getsockopt", err)
+	}
+	if name == "" {
+		return 0, nil
+	}
+	return 1, nil
+}
+
 func setsockoptReuseAddr(fd int, onoff int) error {
 	return os.NewSyscallError(
 		"setsockopt",
@@ -118,3 +208,471 @@ func setsockoptReusePort(fd int, onoff int) error {
 func getsockoptReusePort(fd int) (int, error) {
 	return syscall.GetsockoptInt(fd, syscall.SOL_SOCKET, DARWIN_SO_REUSEPORT)
 }
+
+func getsockoptTCPState(fd int) (State, error) {
+	return StateUnknown, nil // not support
+}
+
+func getsockoptError(fd int) (int, error) {
+	return syscall.GetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_ERROR)
+}
+
+func recvPeek(fd int) (int, error) {
+	buf := [1]byte{}
+	n, _, err := syscall.Recvfrom(fd, buf[:], syscall.MSG_PEEK|syscall.MSG_DONTWAIT)
+	return n, err
+}
+
+func getsockoptNoDelay(fd int) (int, error) {
+	return syscall.GetsockoptInt(fd, syscall.IPPROTO_TCP, syscall.TCP_NODELAY)
+}
+
+func setsockoptCork(fd int, onoff int) error {
+	return os.NewSyscallError(
+		"setsockopt",
+		syscall.SetsockoptInt(fd, syscall.IPPROTO_TCP, DARWIN_TCP_NOPUSH, onoff),
+	)
+}
+
+func getsockoptCork(fd int) (int, error) {
+	return syscall.GetsockoptInt(fd, syscall.IPPROTO_TCP, DARWIN_TCP_NOPUSH)
+}
+
+func setsockoptSaveSYN(fd int, onoff int) error {
+	return nil // not support
+}
+
+func getsockoptSaveSYN(fd int) (int, error) {
+	return 0, nil // not support
+}
+
+func getsockoptSavedSYN(fd int) ([]byte, error) {
+	return nil, nil // not support
+}
+
+func setsockoptMaxSeg(fd int, mss int) error {
+	return os.NewSyscallError(
+		"setsockopt",
+		syscall.SetsockoptInt(fd, syscall.IPPROTO_TCP, syscall.TCP_MAXSEG, mss),
+	)
+}
+
+func getsockoptMaxSeg(fd int) (int, error) {
+	return syscall.GetsockoptInt(fd, syscall.IPPROTO_TCP, syscall.TCP_MAXSEG)
+}
+
+func setsockoptWindowClamp(fd int, bytes int) error {
+	return nil // not support
+}
+
+func getsockoptWindowClamp(fd int) (int, error) {
+	return 0, nil // not support
+}
+
+func getsockoptMSSInfo(fd int) (MSSInfo, error) {
+	mss, err := getsockoptMaxSeg(fd)
+	if err != nil {
+		return MSSInfo{}, err
+	}
+	return MSSInfo{SndMSS: mss}, nil
+}
+
+func setsockoptSynCount(fd int, count int) error {
+	return nil // not support
+}
+
+func getsockoptSynCount(fd int) (int, error) {
+	return 0, nil // not support
+}
+
+func setsockoptThinLinearTimeouts(fd int, onoff int) error {
+	return nil // not support
+}
+
+func getsockoptThinLinearTimeouts(fd int) (int, error) {
+	return 0, nil // not support
+}
+
+// TCP_RXT_CONNDROPTIME takes seconds
+func setsockoptUserTimeout(fd int, d time.Duration) error {
+	return os.NewSyscallError(
+		"setsockopt",
+		syscall.SetsockoptInt(fd, syscall.IPPROTO_TCP, DARWIN_TCP_RXT_CONNDROPTIME, CeilSecond(d)),
+	)
+}
+
+func getsockoptUserTimeout(fd int) (int, error) {
+	sec, err := syscall.GetsockoptInt(fd, syscall.IPPROTO_TCP, DARWIN_TCP_RXT_CONNDROPTIME)
+	return sec * 1000, err
+}
+
+func setsockoptMaxPacingRate(fd int, bytesPerSec uint64) error {
+	return nil // not support
+}
+
+func getsockoptMaxPacingRate(fd int) (uint64, error) {
+	return 0, nil // not support
+}
+
+func getsockoptPacingRate(fd int) (uint64, error) {
+	return 0, nil // not support
+}
+
+func getsockoptBDPSample(fd int) (time.Duration, uint64, error) {
+	return 0, 0, nil // not support
+}
+
+func setsockoptSendBuffer(fd int, bytes int) error {
+	return os.NewSyscallError(
+		"setsockopt",
+		syscall.SetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_SNDBUF, bytes),
+	)
+}
+
+func getsockoptSendBuffer(fd int) (int, error) {
+	return syscall.GetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_SNDBUF)
+}
+
+func setsockoptRecvBuffer(fd int, bytes int) error {
+	return os.NewSyscallError(
+		"setsockopt",
+		syscall.SetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_RCVBUF, bytes),
+	)
+}
+
+func getsockoptRecvBuffer(fd int) (int, error) {
+	return syscall.GetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_RCVBUF)
+}
+
+func setsockoptBufLock(fd int, lock int) error {
+	return nil // not support
+}
+
+func getsockoptBufLock(fd int) (int, error) {
+	return 0, nil // not support
+}
+
+func setsockoptNotSentLowat(fd int, bytes int) error {
+	return os.NewSyscallError(
+		"setsockopt",
+		syscall.SetsockoptInt(fd, syscall.IPPROTO_TCP, DARWIN_TCP_NOTSENT_LOWAT, bytes),
+	)
+}
+
+func getsockoptNotSentLowat(fd int) (int, error) {
+	return syscall.GetsockoptInt(fd, syscall.IPPROTO_TCP, DARWIN_TCP_NOTSENT_LOWAT)
+}
+
+func autotuneMax() (int, int) {
+	return 0, 0 // not support
+}
+
+func pinBufferMax() (int, int) {
+	return 0, 0 // not support
+}
+
+func bufferRequest(size int) int {
+	return size
+}
+
+func setsockoptSendBufferForce(fd int, bytes int) error {
+	return syscall.ENOPROTOOPT // not support
+}
+
+func setsockoptRecvBufferForce(fd int, bytes int) error {
+	return syscall.ENOPROTOOPT // not support
+}
+
+func setsockoptTOS(fd int, tos int) error {
+	return os.NewSyscallError(
+		"setsockopt",
+		syscall.SetsockoptInt(fd, syscall.IPPROTO_IP, syscall.IP_TOS, tos),
+	)
+}
+
+func getsockoptTOS(fd int) (int, error) {
+	return syscall.GetsockoptInt(fd, syscall.IPPROTO_IP, syscall.IP_TOS)
+}
+
+func setsockoptTrafficClass(fd int, tclass int) error {
+	return os.NewSyscallError(
+		"setsockopt",
+		syscall.SetsockoptInt(fd, syscall.IPPROTO_IPV6, syscall.IPV6_TCLASS, tclass),
+	)
+}
+
+func getsockoptTrafficClass(fd int) (int, error) {
+	return syscall.GetsockoptInt(fd, syscall.IPPROTO_IPV6, syscall.IPV6_TCLASS)
+}
+
+func setsockoptMark(fd int, mark uint32) error {
+	return nil // not support
+}
+
+func getsockoptMark(fd int) (uint32, error) {
+	return 0, nil // not support
+}
+
+func setsockoptPriority(fd int, priority int) error {
+	return nil // not support
+}
+
+func getsockoptPriority(fd int) (int, error) {
+	return 0, nil // not support
+}
+
+// IP_BOUND_IF / IPV6_BOUND_IF bind by interface index
+func setsockoptBindToDevice(fd int, name string) error {
+	if name == "" {
+		return setsockoptBindToIfindex(fd, 0)
+	}
+	ifi, err := net.InterfaceByName(name)
+	if err != nil {
+		return err
+	}
+	return setsockoptBindToIfindex(fd, ifi.Index)
+}
+
+func getsockoptBindToDevice(fd int) (string, error) {
+	ifindex, err := getsockoptBindToIfindex(fd)
+	if err != nil {
+		return "", err
+	}
+	if ifindex == 0 {
+		return "", nil
+	}
+	ifi, err := net.InterfaceByIndex(ifindex)
+	if err != nil {
+		return "", err
+	}
+	return ifi.Name, nil
+}
+
+func setsockoptBindToIfindex(fd int, ifindex int) error {
+	ipv6, err := isIPv6Socket(fd)
+	if err != nil {
+		return err
+	}
+	if ipv6 {
+		return os.NewSyscallError(
+			"setsockopt",
+			syscall.SetsockoptInt(fd, syscall.IPPROTO_IPV6, DARWIN_IPV6_BOUND_IF, ifindex),
+		)
+	}
+	return os.NewSyscallError(
+		"setsockopt",
+		syscall.SetsockoptInt(fd, syscall.IPPROTO_IP, DARWIN_IP_BOUND_IF, ifindex),
+	)
+}
+
+func getsockoptBindToIfindex(fd int) (int, error) {
+	ipv6, err := isIPv6Socket(fd)
+	if err != nil {
+		return 0, err
+	}
+	if ipv6 {
+		return syscall.GetsockoptInt(fd, syscall.IPPROTO_IPV6, DARWIN_IPV6_BOUND_IF)
+	}
+	return syscall.GetsockoptInt(fd, syscall.IPPROTO_IP, DARWIN_IP_BOUND_IF)
+}
+
+func setsockoptBindAddressNoPort(fd int, enable int) error {
+	return nil // not support
+}
+
+func getsockoptBindAddressNoPort(fd int) (int, error) {
+	return 0, nil // not support
+}
+
+func setsockoptLocalPortRange(fd int, r uint32) error {
+	return nil // not support
+}
+
+func getsockoptLocalPortRange(fd int) (uint32, error) {
+	return 0, nil // not support
+}
+
+func setsockoptTransparent(fd int, enable int) error {
+	return nil // not support
+}
+
+func getsockoptTransparent(fd int) (int, error) {
+	return 0, nil // not support
+}
+
+func setsockoptIPv6Transparent(fd int, enable int) error {
+	return nil // not support
+}
+
+func getsockoptIPv6Transparent(fd int) (int, error) {
+	return 0, nil // not support
+}
+
+func setsockoptFreeBind(fd int, enable int) error {
+	return nil // not support
+}
+
+func getsockoptFreeBind(fd int) (int, error) {
+	return 0, nil // not support
+}
+
+func setsockoptIPv6FreeBind(fd int, enable int) error {
+	return nil // not support
+}
+
+func getsockoptIPv6FreeBind(fd int) (int, error) {
+	return 0, nil // not support
+}
+
+func setsockoptV6Only(fd int, enable int) error {
+	return os.NewSyscallError(
+		"setsockopt",
+		syscall.SetsockoptInt(fd, syscall.IPPROTO_IPV6, syscall.IPV6_V6ONLY, enable),
+	)
+}
+
+func getsockoptV6Only(fd int) (int, error) {
+	return syscall.GetsockoptInt(fd, syscall.IPPROTO_IPV6, syscall.IPV6_V6ONLY)
+}
+
+func setsockoptMTUDiscover(fd int, mode int) error {
+	return nil // not support
+}
+
+func getsockoptMTUDiscover(fd int) (int, error) {
+	return 0, nil // not support
+}
+
+func setsockoptIPv6MTUDiscover(fd int, mode int) error {
+	return nil // not support
+}
+
+func getsockoptIPv6MTUDiscover(fd int) (int, error) {
+	return 0, nil // not support
+}
+
+func getsockoptMTU(fd int) (int, error) {
+	return 0, nil // not support
+}
+
+func getsockoptIPv6MTU(fd int) (int, error) {
+	return 0, nil // not support
+}
+
+func getsockoptTCPInfoPMTU(fd int) (int, error) {
+	return 0, nil // not support
+}
+
+func setsockoptTTL(fd int, ttl int) error {
+	return os.NewSyscallError(
+		"setsockopt",
+		syscall.SetsockoptInt(fd, syscall.IPPROTO_IP, syscall.IP_TTL, ttl),
+	)
+}
+
+func getsockoptTTL(fd int) (int, error) {
+	return syscall.GetsockoptInt(fd, syscall.IPPROTO_IP, syscall.IP_TTL)
+}
+
+func setsockoptHopLimit(fd int, hops int) error {
+	return os.NewSyscallError(
+		"setsockopt",
+		syscall.SetsockoptInt(fd, syscall.IPPROTO_IPV6, syscall.IPV6_UNICAST_HOPS, hops),
+	)
+}
+
+func getsockoptHopLimit(fd int) (int, error) {
+	return syscall.GetsockoptInt(fd, syscall.IPPROTO_IPV6, syscall.IPV6_UNICAST_HOPS)
+}
+
+func setsockoptMinTTL(fd int, ttl int) error {
+	return nil // not support
+}
+
+func getsockoptMinTTL(fd int) (int, error) {
+	return 0, nil // not support
+}
+
+func setsockoptMinHopCount(fd int, hops int) error {
+	return nil // not support
+}
+
+func getsockoptMinHopCount(fd int) (int, error) {
+	return 0, nil // not support
+}
+
+func setsockoptMD5Key(fd int, peer netip.Prefix, key []byte, ifindex int) error {
+	return syscall.ENOPROTOOPT // not support
+}
+
+func setsockoptAOAddKey(fd int, key AOKey, current, rnext bool) error {
+	return syscall.ENOPROTOOPT // not support
+}
+
+func setsockoptAODelKey(fd int, peer netip.Prefix, sendID, recvID uint8, ifindex int) error {
+	return syscall.ENOPROTOOPT // not support
+}
+
+func setsockoptAOInfo(fd int, current, rnext int) error {
+	return syscall.ENOPROTOOPT // not support
+}
+
+func setsockoptAORequired(fd int, required bool) error {
+	return syscall.ENOPROTOOPT // not support
+}
+
+func getsockoptAOInfo(fd int) (AOInfo, error) {
+	return AOInfo{}, syscall.ENOPROTOOPT // not support
+}
+
+func getsockoptAOKeys(fd int) ([]AOKeyInfo, error) {
+	return nil, syscall.ENOPROTOOPT // not support
+}
+
+func setsockoptULP(fd int, name string) error {
+	return syscall.ENOPROTOOPT // not support
+}
+
+func setsockoptKTLS(fd int, dir ktlsDirection, c ktlsCipher) error {
+	return syscall.ENOPROTOOPT // not support
+}
+
+func recvTLSRecord(fd int, p []byte) (int, uint8, error) {
+	return 0, 0, syscall.ENOPROTOOPT // not support
+}
+
+func sendTLSAlert(fd int, alert []byte) error {
+	return syscall.ENOPROTOOPT // not support
+}
+
+func setsockoptZeroCopy(fd int, onoff int) error {
+	return syscall.ENOPROTOOPT // not support
+}
+
+func getsockoptZeroCopy(fd int) (int, error) {
+	return 0, nil // not support
+}
+
+func sendZeroCopy(fd int, p []byte) (int, error) {
+	return 0, syscall.ENOPROTOOPT // not support
+}
+
+func recvZeroCopyCompletions(fd int) ([]zeroCopyCompletion, error) {
+	return nil, nil // not support
+}
+
+func pollErrQueue(fd int, timeout time.Duration) error {
+	return syscall.ENOPROTOOPT // not support
+}
+
+func mmapZeroCopyReceive(fd int, size int) ([]byte, error) {
+	return nil, syscall.ENODEV // not support
+}
+
+func munmapZeroCopyReceive(b []byte) error {
+	return nil // not support
+}
+
+func getsockoptZeroCopyReceive(fd int, mapping []byte) (int, int, int, error) {
+	return 0, 0, 0, syscall.ENOPROTOOPT // not support
+}
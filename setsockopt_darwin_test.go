@@ -9,6 +9,7 @@ import (
 	"sync/atomic"
 	"syscall"
 	"testing"
+	"time"
 )
 
 type testingWrap interface {
@@ -103,7 +104,41 @@ func TestSetFastOpen(t *testing.T) {
 }
 
 func TestSetDeferAccept(t *testing.T) {
-	t.Skip("no support")
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_STREAM, syscall.IPPROTO_TCP)
+	if err != nil {
+		t.Fatalf("socket err: %+v", err)
+	}
+	defer syscall.Close(fd)
+	// SO_ACCEPTFILTER requires listen(), Control path must not fail
+	if err := SetDeferAcceptFd(fd, true); err != nil {
+		t.Errorf("before listen must be skipped: %+v", err)
+	}
+
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen err: %+v", err)
+	}
+	defer listener.Close()
+
+	if err := SetDeferAcceptTimeout(listener, 1500*time.Millisecond); err != nil {
+		t.Fatalf("set err: %+v", err)
+	}
+	d, err := GetDeferAcceptTimeout(listener)
+	if err != nil {
+		t.Fatalf("get err: %+v", err)
+	}
+	if d == 0 {
+		t.Skipf("accept filter dataready not available")
+	}
+	if d != time.Second {
+		t.Errorf("dataready must be reported as 1s, actual:%s", d)
+	}
+	if err := SetDeferAcceptTimeout(listener, 0); err != nil {
+		t.Fatalf("clear err: %+v", err)
+	}
+	if d, err := GetDeferAcceptTimeout(listener); err != nil || d != 0 {
+		t.Errorf("filter must be removed: %s %+v", d, err)
+	}
 }
 
 func TestSetKeepAlive(t *testing.T) {
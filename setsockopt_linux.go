@@ -1,21 +1,33 @@
 package tcpoption
 
 import (
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/netip"
 	"os"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
+	"unsafe"
 
 	"golang.org/x/sys/unix"
 )
 
+// TCP_LINGER2 takes integer seconds, sub-second rounds up as 0 means net.ipv4.tcp_fin_timeout
 func setsockoptLingerTimeout(fd int, d time.Duration) error {
-	tval := syscall.NsecToTimeval(d.Nanoseconds())
 	return os.NewSyscallError(
 		"setsockopt",
-		syscall.SetsockoptTimeval(fd, syscall.IPPROTO_TCP, syscall.TCP_LINGER2, &tval),
+		syscall.SetsockoptInt(fd, syscall.IPPROTO_TCP, syscall.TCP_LINGER2, CeilSecond(d)),
 	)
 }
 
+func getsockoptLingerTimeout(fd int) (int, error) {
+	return syscall.GetsockoptInt(fd, syscall.IPPROTO_TCP, syscall.TCP_LINGER2)
+}
+
 func setsockoptKeepAliveIdle(fd int, sec int) error {
 	return os.NewSyscallError(
 		"setsockopt",
@@ -67,6 +79,75 @@ func setsockoptFastOpenConnect(fd int, count int) error {
 	)
 }
 
+func setsockoptFastOpenKey(fd int, keys []byte) error {
+	return os.NewSyscallError(
+		"setsockopt",
+		unix.SetsockoptString(fd, syscall.IPPROTO_TCP, unix.TCP_FASTOPEN_KEY, string(keys)),
+	)
+}
+
+func getsockoptFastOpenKey(fd int) ([]byte, error) {
+	keys := make([]byte, 2*FastOpenKeyLength)
+	size := uint32(len(keys))
+	if err := getsockopt(fd, syscall.IPPROTO_TCP, unix.TCP_FASTOPEN_KEY, unsafe.Pointer(&keys[0]), &size); err != nil {
+		return nil, err
+	}
+	return keys[:size], nil
+}
+
+func setsockoptFastOpenNoCookie(fd int, onoff int) error {
+	return os.NewSyscallError(
+		"setsockopt",
+		syscall.SetsockoptInt(fd, syscall.IPPROTO_TCP, unix.TCP_FASTOPEN_NO_COOKIE, onoff),
+	)
+}
+
+func getsockoptFastOpenNoCookie(fd int) (int, error) {
+	return syscall.GetsockoptInt(fd, syscall.IPPROTO_TCP, unix.TCP_FASTOPEN_NO_COOKIE)
+}
+
+// fall back to sendto(MSG_FASTOPEN) on kernels without TCP_FASTOPEN_CONNECT(< 4.11),
+// sendto connects implicitly so the following connect(2) reports EALREADY
+func connectFastOpen(fd int, address string, first []byte) (int, error) {
+	err := setsockoptFastOpenConnect(fd, 1)
+	if err == nil {
+		return 0, nil
+	}
+	if errors.Is(err, syscall.ENOPROTOOPT) != true || len(first) == 0 {
+		return 0, err
+	}
+	sa, err := resolveSockaddr(address)
+	if err != nil {
+		return 0, err
+	}
+	n, err := syscall.SendmsgN(fd, first, nil, sa, unix.MSG_FASTOPEN)
+	if err != nil {
+		if err == syscall.EINPROGRESS {
+			return 0, nil // no cookie, SYN without data
+		}
+		return 0, os.NewSyscallError("sendmsg", err)
+	}
+	return n, nil
+}
+
+func getsockoptFastOpenResult(fd int) (FastOpenResult, bool, error) {
+	info, err := getsockoptTCPInfo(fd)
+	if err != nil {
+		return FastOpenResult{}, false, err
+	}
+	if int(info.State) == LINUX_TCP_SYN_SENT {
+		return FastOpenResult{}, false, nil
+	}
+	fail := int(info.Flags>>1) & 0x3
+	accepted := (int(info.Options) & LINUX_TCPI_OPT_SYN_DATA) != 0
+	return FastOpenResult{
+		SentInSYN:     accepted || fail == LINUX_TFO_DATA_NOT_ACKED || fail == LINUX_TFO_SYN_RETRANSMITTED,
+		Accepted:      accepted,
+		CookieMissing: fail == LINUX_TFO_COOKIE_UNAVAILABLE,
+		Fallback:      accepted != true,
+	}, true, nil
+}
+
 func setsockoptQuickACK(fd int, onoff int) error {
 	return os.NewSyscallError(
 		"setsockopt",
@@ -74,10 +155,14 @@ func setsockoptQuickACK(fd int, onoff int) error {
 	)
 }
 
-func setsockoptDeferAccept(fd int, onoff int) error {
+func getsockoptQuickACK(fd int) (int, error) {
+	return syscall.GetsockoptInt(fd, syscall.IPPROTO_TCP, syscall.TCP_QUICKACK)
+}
+
+func setsockoptDeferAccept(fd int, sec int) error {
 	return os.NewSyscallError(
 		"setsockopt",
-		syscall.SetsockoptInt(fd, syscall.IPPROTO_TCP, syscall.TCP_DEFER_ACCEPT, onoff),
+		syscall.SetsockoptInt(fd, syscall.IPPROTO_TCP, syscall.TCP_DEFER_ACCEPT, sec),
 	)
 }
 
@@ -106,3 +191,1139 @@ func setsockoptReusePort(fd int, onoff int) error {
 func getsockoptReusePort(fd int) (int, error) {
 	return syscall.GetsockoptInt(fd, syscall.SOL_SOCKET, unix.SO_REUSEPORT)
 }
+
+// include/net/tcp_states.h
+const (
+	LINUX_TCP_ESTABLISHED int = 1
+	LINUX_TCP_SYN_SENT    int = 2
+	LINUX_TCP_SYN_RECV    int = 3
+	LINUX_TCP_FIN_WAIT1   int = 4
+	LINUX_TCP_FIN_WAIT2   int = 5
+	LINUX_TCP_TIME_WAIT   int = 6
+	LINUX_TCP_CLOSE       int = 7
+	LINUX_TCP_CLOSE_WAIT  int = 8
+	LINUX_TCP_LAST_ACK    int = 9
+	LINUX_TCP_LISTEN      int = 10
+	LINUX_TCP_CLOSING     int = 11
+)
+
+// linux/in.h, not in x/sys yet (6.3+)
+const (
+	LINUX_IP_LOCAL_PORT_RANGE int = 51
+)
+
+// linux/tcp.h
+const (
+	LINUX_TCPI_OPT_SYN_DATA int = 0x20
+)
+
+// linux/tcp.h enum tcp_fastopen_client_fail
+const (
+	LINUX_TFO_STATUS_UNSPEC      int = 0
+	LINUX_TFO_COOKIE_UNAVAILABLE int = 1
+	LINUX_TFO_DATA_NOT_ACKED     int = 2
+	LINUX_TFO_SYN_RETRANSMITTED  int = 3
+)
+
+// linux/tcp.h struct tcp_info
+// unix.TCPInfo only covers the fields up to tcpi_total_retrans
+type tcpInfo struct {
+	State         uint8
+	CaState       uint8
+	Retransmits   uint8
+	Probes        uint8
+	Backoff       uint8
+	Options       uint8
+	Wscale        uint8 // snd_wscale:4, rcv_wscale:4
+	Flags         uint8 // delivery_rate_app_limited:1, fastopen_client_fail:2
+	Rto           uint32
+	Ato           uint32
+	SndMss        uint32
+	RcvMss        uint32
+	Unacked       uint32
+	Sacked        uint32
+	Lost          uint32
+	Retrans       uint32
+	Fackets       uint32
+	LastDataSent  uint32
+	LastAckSent   uint32
+	LastDataRecv  uint32
+	LastAckRecv   uint32
+	Pmtu          uint32
+	RcvSsthresh   uint32
+	Rtt           uint32
+	Rttvar        uint32
+	SndSsthresh   uint32
+	SndCwnd       uint32
+	Advmss        uint32
+	Reordering    uint32
+	RcvRtt        uint32
+	RcvSpace      uint32
+	TotalRetrans  uint32
+	PacingRate    uint64
+	MaxPacingRate uint64
+	BytesAcked    uint64
+	BytesReceived uint64
+	SegsOut       uint32
+	SegsIn        uint32
+	NotsentBytes  uint32
+	MinRtt        uint32
+	DataSegsIn    uint32
+	DataSegsOut   uint32
+	DeliveryRate  uint64
+	BusyTime      uint64
+	RwndLimited   uint64
+	SndbufLimited uint64
+	Delivered     uint32
+	DeliveredCe   uint32
+	BytesSent     uint64
+	BytesRetrans  uint64
+	DsackDups     uint32
+	ReordSeen     uint32
+	RcvOoopack    uint32
+	SndWnd        uint32
+}
+
+func getsockopt(fd int, level int, opt int, val unsafe.Pointer, size *uint32) error {
+	_, _, errno := unix.Syscall6(
+		unix.SYS_GETSOCKOPT,
+		uintptr(fd),
+		uintptr(level),
+		uintptr(opt),
+		uintptr(val),
+		uintptr(unsafe.Pointer(size)),
+		0,
+	)
+	if errno != 0 {
+		return os.NewSyscallError("getsockopt", errno)
+	}
+	return nil
+}
+
+// older kernels fill only a prefix of tcpInfo, the rest stays zero
+func getsockoptTCPInfo(fd int) (*tcpInfo, error) {
+	info := new(tcpInfo)
+	size := uint32(unsafe.Sizeof(*info))
+	if err := getsockopt(fd, syscall.IPPROTO_TCP, syscall.TCP_INFO, unsafe.Pointer(info), &size); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+func getsockoptTCPState(fd int) (State, error) {
+	info, err := getsockoptTCPInfo(fd)
+	if err != nil {
+		return StateUnknown, err
+	}
+	switch int(info.State) {
+	case LINUX_TCP_ESTABLISHED:
+		return StateEstablished, nil
+	case LINUX_TCP_CLOSE_WAIT:
+		return StatePeerClosed, nil
+	case LINUX_TCP_CLOSE:
+		return StateReset, nil
+	}
+	return StateBroken, nil
+}
+
+func getsockoptError(fd int) (int, error) {
+	return syscall.GetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_ERROR)
+}
+
+func recvPeek(fd int) (int, error) {
+	buf := [1]byte{}
+	n, _, err := syscall.Recvfrom(fd, buf[:], syscall.MSG_PEEK|syscall.MSG_DONTWAIT)
+	return n, err
+}
+
+func getsockoptNoDelay(fd int) (int, error) {
+	return syscall.GetsockoptInt(fd, syscall.IPPROTO_TCP, syscall.TCP_NODELAY)
+}
+
+func setsockoptCork(fd int, onoff int) error {
+	return os.NewSyscallError(
+		"setsockopt",
+		syscall.SetsockoptInt(fd, syscall.IPPROTO_TCP, unix.TCP_CORK, onoff),
+	)
+}
+
+func getsockoptCork(fd int) (int, error) {
+	return syscall.GetsockoptInt(fd, syscall.IPPROTO_TCP, unix.TCP_CORK)
+}
+
+func setsockoptSaveSYN(fd int, onoff int) error {
+	return os.NewSyscallError(
+		"setsockopt",
+		syscall.SetsockoptInt(fd, syscall.IPPROTO_TCP, unix.TCP_SAVE_SYN, onoff),
+	)
+}
+
+func getsockoptSaveSYN(fd int) (int, error) {
+	return syscall.GetsockoptInt(fd, syscall.IPPROTO_TCP, unix.TCP_SAVE_SYN)
+}
+
+func getsockoptSavedSYN(fd int) ([]byte, error) {
+	buf := make([]byte, 512)
+	size := uint32(len(buf))
+	if err := getsockopt(fd, syscall.IPPROTO_TCP, unix.TCP_SAVED_SYN, unsafe.Pointer(&buf[0]), &size); err != nil {
+		return nil, err
+	}
+	return buf[:size], nil
+}
+
+func setsockoptMaxSeg(fd int, mss int) error {
+	return os.NewSyscallError(
+		"setsockopt",
+		syscall.SetsockoptInt(fd, syscall.IPPROTO_TCP, syscall.TCP_MAXSEG, mss),
+	)
+}
+
+func getsockoptMaxSeg(fd int) (int, error) {
+	return syscall.GetsockoptInt(fd, syscall.IPPROTO_TCP, syscall.TCP_MAXSEG)
+}
+
+func setsockoptWindowClamp(fd int, bytes int) error {
+	return os.NewSyscallError(
+		"setsockopt",
+		syscall.SetsockoptInt(fd, syscall.IPPROTO_TCP, unix.TCP_WINDOW_CLAMP, bytes),
+	)
+}
+
+func getsockoptWindowClamp(fd int) (int, error) {
+	return syscall.GetsockoptInt(fd, syscall.IPPROTO_TCP, unix.TCP_WINDOW_CLAMP)
+}
+
+func getsockoptMSSInfo(fd int) (MSSInfo, error) {
+	info, err := getsockoptTCPInfo(fd)
+	if err != nil {
+		return MSSInfo{}, err
+	}
+	return MSSInfo{
+		SndMSS: int(info.SndMss),
+		RcvMSS: int(info.RcvMss),
+		AdvMSS: int(info.Advmss),
+	}, nil
+}
+
+func setsockoptSynCount(fd int, count int) error {
+	return os.NewSyscallError(
+		"setsockopt",
+		syscall.SetsockoptInt(fd, syscall.IPPROTO_TCP, syscall.TCP_SYNCNT, count),
+	)
+}
+
+func getsockoptSynCount(fd int) (int, error) {
+	return syscall.GetsockoptInt(fd, syscall.IPPROTO_TCP, syscall.TCP_SYNCNT)
+}
+
+func setsockoptThinLinearTimeouts(fd int, onoff int) error {
+	return os.NewSyscallError(
+		"setsockopt",
+		syscall.SetsockoptInt(fd, syscall.IPPROTO_TCP, unix.TCP_THIN_LINEAR_TIMEOUTS, onoff),
+	)
+}
+
+func getsockoptThinLinearTimeouts(fd int) (int, error) {
+	return syscall.GetsockoptInt(fd, syscall.IPPROTO_TCP, unix.TCP_THIN_LINEAR_TIMEOUTS)
+}
+
+func setsockoptUserTimeout(fd int, d time.Duration) error {
+	return os.NewSyscallError(
+		"setsockopt",
+		syscall.SetsockoptInt(fd, syscall.IPPROTO_TCP, unix.TCP_USER_TIMEOUT, int(d.Milliseconds())),
+	)
+}
+
+func getsockoptUserTimeout(fd int) (int, error) {
+	return syscall.GetsockoptInt(fd, syscall.IPPROTO_TCP, unix.TCP_USER_TIMEOUT)
+}
+
+func setsockoptMaxPacingRate(fd int, bytesPerSec uint64) error {
+	return os.NewSyscallError(
+		"setsockopt",
+		unix.SetsockoptUint64(fd, syscall.SOL_SOCKET, unix.SO_MAX_PACING_RATE, bytesPerSec),
+	)
+}
+
+func getsockoptMaxPacingRate(fd int) (uint64, error) {
+	return unix.GetsockoptUint64(fd, syscall.SOL_SOCKET, unix.SO_MAX_PACING_RATE)
+}
+
+func getsockoptPacingRate(fd int) (uint64, error) {
+	info, err := getsockoptTCPInfo(fd)
+	if err != nil {
+		return 0, err
+	}
+	return info.PacingRate, nil
+}
+
+func getsockoptBDPSample(fd int) (time.Duration, uint64, error) {
+	info, err := getsockoptTCPInfo(fd)
+	if err != nil {
+		return 0, 0, err
+	}
+	return time.Duration(info.MinRtt) * time.Microsecond, info.DeliveryRate, nil
+}
+
+func setsockoptSendBuffer(fd int, bytes int) error {
+	return os.NewSyscallError(
+		"setsockopt",
+		syscall.SetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_SNDBUF, bytes),
+	)
+}
+
+func getsockoptSendBuffer(fd int) (int, error) {
+	return syscall.GetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_SNDBUF)
+}
+
+func setsockoptRecvBuffer(fd int, bytes int) error {
+	return os.NewSyscallError(
+		"setsockopt",
+		syscall.SetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_RCVBUF, bytes),
+	)
+}
+
+// SO_SNDBUFFORCE ignores net.core.wmem_max
+func setsockoptSendBufferForce(fd int, bytes int) error {
+	return privilegeError("SO_SNDBUFFORCE", "CAP_NET_ADMIN", os.NewSyscallError(
+		"setsockopt",
+		syscall.SetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_SNDBUFFORCE, bytes),
+	))
+}
+
+// SO_RCVBUFFORCE ignores net.core.rmem_max
+func setsockoptRecvBufferForce(fd int, bytes int) error {
+	return privilegeError("SO_RCVBUFFORCE", "CAP_NET_ADMIN", os.NewSyscallError(
+		"setsockopt",
+		syscall.SetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_RCVBUFFORCE, bytes),
+	))
+}
+
+func getsockoptRecvBuffer(fd int) (int, error) {
+	return syscall.GetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_RCVBUF)
+}
+
+// SO_BUF_LOCK(5.14+) releases SOCK_SNDBUF_LOCK/SOCK_RCVBUF_LOCK, autotuning resumes
+func setsockoptBufLock(fd int, lock int) error {
+	return os.NewSyscallError(
+		"setsockopt",
+		syscall.SetsockoptInt(fd, syscall.SOL_SOCKET, unix.SO_BUF_LOCK, lock),
+	)
+}
+
+func getsockoptBufLock(fd int) (int, error) {
+	return syscall.GetsockoptInt(fd, syscall.SOL_SOCKET, unix.SO_BUF_LOCK)
+}
+
+func setsockoptNotSentLowat(fd int, bytes int) error {
+	return os.NewSyscallError(
+		"setsockopt",
+		syscall.SetsockoptInt(fd, syscall.IPPROTO_TCP, unix.TCP_NOTSENT_LOWAT, bytes),
+	)
+}
+
+func getsockoptNotSentLowat(fd int) (int, error) {
+	return syscall.GetsockoptInt(fd, syscall.IPPROTO_TCP, unix.TCP_NOTSENT_LOWAT)
+}
+
+// net.ipv4.tcp_rmem / tcp_wmem max, the ceiling autotuning grows buffers to
+func autotuneMax() (int, int) {
+	return readSysctlField("/proc/sys/net/ipv4/tcp_rmem", 2), readSysctlField("/proc/sys/net/ipv4/tcp_wmem", 2)
+}
+
+// SO_RCVBUF / SO_SNDBUF are capped at net.core.rmem_max / wmem_max and doubled for bookkeeping overhead,
+// returns the largest value getsockopt reads back
+func pinBufferMax() (int, int) {
+	return 2 * readSysctlField("/proc/sys/net/core/rmem_max", 0), 2 * readSysctlField("/proc/sys/net/core/wmem_max", 0)
+}
+
+// bufferRequest returns the setsockopt value read back as size
+func bufferRequest(size int) int {
+	return (size + 1) / 2
+}
+
+func readSysctlField(path string, i int) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) <= i {
+		return 0
+	}
+	v, err := strconv.Atoi(fields[i])
+	if err != nil {
+		return 0
+	}
+	return v
+}
+
+func setsockoptTOS(fd int, tos int) error {
+	return os.NewSyscallError(
+		"setsockopt",
+		syscall.SetsockoptInt(fd, syscall.IPPROTO_IP, syscall.IP_TOS, tos),
+	)
+}
+
+func getsockoptTOS(fd int) (int, error) {
+	return syscall.GetsockoptInt(fd, syscall.IPPROTO_IP, syscall.IP_TOS)
+}
+
+func setsockoptTrafficClass(fd int, tclass int) error {
+	return os.NewSyscallError(
+		"setsockopt",
+		syscall.SetsockoptInt(fd, syscall.IPPROTO_IPV6, syscall.IPV6_TCLASS, tclass),
+	)
+}
+
+func getsockoptTrafficClass(fd int) (int, error) {
+	return syscall.GetsockoptInt(fd, syscall.IPPROTO_IPV6, syscall.IPV6_TCLASS)
+}
+
+// SO_MARK requires CAP_NET_ADMIN (CAP_NET_RAW is also accepted on 5.17+)
+func setsockoptMark(fd int, mark uint32) error {
+	return privilegeError("SO_MARK", "CAP_NET_ADMIN", os.NewSyscallError(
+		"setsockopt",
+		syscall.SetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_MARK, int(mark)),
+	))
+}
+
+func getsockoptMark(fd int) (uint32, error) {
+	v, err := syscall.GetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_MARK)
+	if err != nil {
+		return 0, err
+	}
+	return uint32(v), nil
+}
+
+func setsockoptPriority(fd int, priority int) error {
+	return privilegeError("SO_PRIORITY "+strconv.Itoa(priority), "CAP_NET_ADMIN", os.NewSyscallError(
+		"setsockopt",
+		syscall.SetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_PRIORITY, priority),
+	))
+}
+
+func getsockoptPriority(fd int) (int, error) {
+	return syscall.GetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_PRIORITY)
+}
+
+const (
+	bindToDeviceCapability string = "CAP_NET_RAW (kernel 5.7+ allows unprivileged bind when the socket is not bound to a device yet)"
+)
+
+func setsockoptBindToDevice(fd int, name string) error {
+	return privilegeError("SO_BINDTODEVICE "+strconv.Quote(name), bindToDeviceCapability, os.NewSyscallError(
+		"setsockopt",
+		syscall.SetsockoptString(fd, syscall.SOL_SOCKET, syscall.SO_BINDTODEVICE, name),
+	))
+}
+
+// unix.GetsockoptString panics on the zero length value of an unbound socket
+func getsockoptBindToDevice(fd int) (string, error) {
+	buf := make([]byte, unix.IFNAMSIZ)
+	size := uint32(len(buf))
+	if err := getsockopt(fd, syscall.SOL_SOCKET, syscall.SO_BINDTODEVICE, unsafe.Pointer(&buf[0]), &size); err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(buf[:size]), "\x00"), nil
+}
+
+func setsockoptBindToIfindex(fd int, ifindex int) error {
+	return privilegeError("SO_BINDTOIFINDEX "+strconv.Itoa(ifindex), bindToDeviceCapability, os.NewSyscallError(
+		"setsockopt",
+		syscall.SetsockoptInt(fd, syscall.SOL_SOCKET, unix.SO_BINDTOIFINDEX, ifindex),
+	))
+}
+
+func getsockoptBindToIfindex(fd int) (int, error) {
+	return syscall.GetsockoptInt(fd, syscall.SOL_SOCKET, unix.SO_BINDTOIFINDEX)
+}
+
+// IP_BIND_ADDRESS_NO_PORT(4.2+) also applies to AF_INET6 sockets
+func setsockoptBindAddressNoPort(fd int, enable int) error {
+	return os.NewSyscallError(
+		"setsockopt",
+		syscall.SetsockoptInt(fd, syscall.IPPROTO_IP, unix.IP_BIND_ADDRESS_NO_PORT, enable),
+	)
+}
+
+func getsockoptBindAddressNoPort(fd int) (int, error) {
+	return syscall.GetsockoptInt(fd, syscall.IPPROTO_IP, unix.IP_BIND_ADDRESS_NO_PORT)
+}
+
+func setsockoptLocalPortRange(fd int, r uint32) error {
+	return os.NewSyscallError(
+		"setsockopt",
+		syscall.SetsockoptInt(fd, syscall.IPPROTO_IP, LINUX_IP_LOCAL_PORT_RANGE, int(r)),
+	)
+}
+
+func getsockoptLocalPortRange(fd int) (uint32, error) {
+	v := uint32(0)
+	size := uint32(unsafe.Sizeof(v))
+	if err := getsockopt(fd, syscall.IPPROTO_IP, LINUX_IP_LOCAL_PORT_RANGE, unsafe.Pointer(&v), &size); err != nil {
+		return 0, err
+	}
+	return v, nil
+}
+
+func setsockoptTransparent(fd int, enable int) error {
+	return privilegeError("IP_TRANSPARENT", "CAP_NET_ADMIN", os.NewSyscallError(
+		"setsockopt",
+		syscall.SetsockoptInt(fd, syscall.IPPROTO_IP, unix.IP_TRANSPARENT, enable),
+	))
+}
+
+func getsockoptTransparent(fd int) (int, error) {
+	return syscall.GetsockoptInt(fd, syscall.IPPROTO_IP, unix.IP_TRANSPARENT)
+}
+
+func setsockoptIPv6Transparent(fd int, enable int) error {
+	return privilegeError("IPV6_TRANSPARENT", "CAP_NET_ADMIN", os.NewSyscallError(
+		"setsockopt",
+		syscall.SetsockoptInt(fd, syscall.IPPROTO_IPV6, unix.IPV6_TRANSPARENT, enable),
+	))
+}
+
+func getsockoptIPv6Transparent(fd int) (int, error) {
+	return syscall.GetsockoptInt(fd, syscall.IPPROTO_IPV6, unix.IPV6_TRANSPARENT)
+}
+
+func setsockoptFreeBind(fd int, enable int) error {
+	return os.NewSyscallError(
+		"setsockopt",
+		syscall.SetsockoptInt(fd, syscall.IPPROTO_IP, unix.IP_FREEBIND, enable),
+	)
+}
+
+func getsockoptFreeBind(fd int) (int, error) {
+	return syscall.GetsockoptInt(fd, syscall.IPPROTO_IP, unix.IP_FREEBIND)
+}
+
+// IPV6_FREEBIND(4.15+)
+func setsockoptIPv6FreeBind(fd int, enable int) error {
+	return os.NewSyscallError(
+		"setsockopt",
+		syscall.SetsockoptInt(fd, syscall.IPPROTO_IPV6, unix.IPV6_FREEBIND, enable),
+	)
+}
+
+func getsockoptIPv6FreeBind(fd int) (int, error) {
+	return syscall.GetsockoptInt(fd, syscall.IPPROTO_IPV6, unix.IPV6_FREEBIND)
+}
+
+func setsockoptV6Only(fd int, enable int) error {
+	return os.NewSyscallError(
+		"setsockopt",
+		syscall.SetsockoptInt(fd, syscall.IPPROTO_IPV6, syscall.IPV6_V6ONLY, enable),
+	)
+}
+
+func getsockoptV6Only(fd int) (int, error) {
+	return syscall.GetsockoptInt(fd, syscall.IPPROTO_IPV6, syscall.IPV6_V6ONLY)
+}
+
+func setsockoptMTUDiscover(fd int, mode int) error {
+	return os.NewSyscallError(
+		"setsockopt",
+		syscall.SetsockoptInt(fd, syscall.IPPROTO_IP, unix.IP_MTU_DISCOVER, mode),
+	)
+}
+
+func getsockoptMTUDiscover(fd int) (int, error) {
+	return syscall.GetsockoptInt(fd, syscall.IPPROTO_IP, unix.IP_MTU_DISCOVER)
+}
+
+func setsockoptIPv6MTUDiscover(fd int, mode int) error {
+	return os.NewSyscallError(
+		"setsockopt",
+		syscall.SetsockoptInt(fd, syscall.IPPROTO_IPV6, unix.IPV6_MTU_DISCOVER, mode),
+	)
+}
+
+func getsockoptIPv6MTUDiscover(fd int) (int, error) {
+	return syscall.GetsockoptInt(fd, syscall.IPPROTO_IPV6, unix.IPV6_MTU_DISCOVER)
+}
+
+func getsockoptMTU(fd int) (int, error) {
+	return syscall.GetsockoptInt(fd, syscall.IPPROTO_IP, unix.IP_MTU)
+}
+
+func getsockoptIPv6MTU(fd int) (int, error) {
+	return syscall.GetsockoptInt(fd, syscall.IPPROTO_IPV6, unix.IPV6_MTU)
+}
+
+func getsockoptTCPInfoPMTU(fd int) (int, error) {
+	info, err := getsockoptTCPInfo(fd)
+	if err != nil {
+		return 0, err
+	}
+	return int(info.Pmtu), nil
+}
+
+func setsockoptTTL(fd int, ttl int) error {
+	return os.NewSyscallError(
+		"setsockopt",
+		syscall.SetsockoptInt(fd, syscall.IPPROTO_IP, syscall.IP_TTL, ttl),
+	)
+}
+
+func getsockoptTTL(fd int) (int, error) {
+	return syscall.GetsockoptInt(fd, syscall.IPPROTO_IP, syscall.IP_TTL)
+}
+
+func setsockoptHopLimit(fd int, hops int) error {
+	return os.NewSyscallError(
+		"setsockopt",
+		syscall.SetsockoptInt(fd, syscall.IPPROTO_IPV6, syscall.IPV6_UNICAST_HOPS, hops),
+	)
+}
+
+func getsockoptHopLimit(fd int) (int, error) {
+	return syscall.GetsockoptInt(fd, syscall.IPPROTO_IPV6, syscall.IPV6_UNICAST_HOPS)
+}
+
+func setsockoptMinTTL(fd int, ttl int) error {
+	return os.NewSyscallError(
+		"setsockopt",
+		syscall.SetsockoptInt(fd, syscall.IPPROTO_IP, unix.IP_MINTTL, ttl),
+	)
+}
+
+func getsockoptMinTTL(fd int) (int, error) {
+	return syscall.GetsockoptInt(fd, syscall.IPPROTO_IP, unix.IP_MINTTL)
+}
+
+func setsockoptMinHopCount(fd int, hops int) error {
+	return os.NewSyscallError(
+		"setsockopt",
+		syscall.SetsockoptInt(fd, syscall.IPPROTO_IPV6, unix.IPV6_MINHOPCOUNT, hops),
+	)
+}
+
+func getsockoptMinHopCount(fd int) (int, error) {
+	return syscall.GetsockoptInt(fd, syscall.IPPROTO_IPV6, unix.IPV6_MINHOPCOUNT)
+}
+
+// linux/tcp.h
+const (
+	LINUX_TCP_MD5SIG_FLAG_PREFIX  uint8 = 0x1
+	LINUX_TCP_MD5SIG_FLAG_IFINDEX uint8 = 0x2
+)
+
+// struct tcp_md5sig, x/sys TCPMD5Sig lacks tcpm_ifindex
+type tcpMD5Sig struct {
+	Addr      [128]byte // struct __kernel_sockaddr_storage
+	Flags     uint8
+	Prefixlen uint8
+	Keylen    uint16
+	Ifindex   int32
+	Key       [80]uint8
+}
+
+func setsockoptMD5Key(fd int, peer netip.Prefix, key []byte, ifindex int) error {
+	sig := tcpMD5Sig{
+		Keylen: uint16(len(key)),
+	}
+	copy(sig.Key[:], key)
+	if err := putSockaddrStorage(&sig.Addr, fd, peer); err != nil {
+		return err
+	}
+
+	opt := syscall.TCP_MD5SIG
+	if peer.Bits() != peer.Addr().BitLen() {
+		opt = unix.TCP_MD5SIG_EXT
+		sig.Flags |= LINUX_TCP_MD5SIG_FLAG_PREFIX
+		sig.Prefixlen = uint8(peer.Bits())
+	}
+	if ifindex != 0 {
+		opt = unix.TCP_MD5SIG_EXT
+		sig.Flags |= LINUX_TCP_MD5SIG_FLAG_IFINDEX
+		sig.Ifindex = int32(ifindex)
+	}
+	buf := (*[unsafe.Sizeof(sig)]byte)(unsafe.Pointer(&sig))[:]
+	return os.NewSyscallError(
+		"setsockopt",
+		unix.SetsockoptString(fd, syscall.IPPROTO_TCP, opt, string(buf)),
+	)
+}
+
+// putSockaddrStorage encodes peer for the family of fd,
+// AF_INET6 sockets take IPv4 peers as v4-mapped address with IPv4 prefix length
+func putSockaddrStorage(ss *[128]byte, fd int, peer netip.Prefix) error {
+	ipv6, err := isIPv6Socket(fd)
+	if err != nil {
+		return err
+	}
+	addr := peer.Addr()
+	if ipv6 {
+		sa := (*unix.RawSockaddrInet6)(unsafe.Pointer(ss))
+		sa.Family = unix.AF_INET6
+		sa.Addr = addr.As16()
+		return nil
+	}
+	if addr.Is4() != true {
+		return fmt.Errorf("ipv6 peer %s on ipv4 socket", peer)
+	}
+	sa := (*unix.RawSockaddrInet4)(unsafe.Pointer(ss))
+	sa.Family = unix.AF_INET
+	sa.Addr = addr.As4()
+	return nil
+}
+
+func sockaddrStoragePrefix(ss *[128]byte, bits int) netip.Prefix {
+	switch (*unix.RawSockaddr)(unsafe.Pointer(ss)).Family {
+	case unix.AF_INET:
+		sa := (*unix.RawSockaddrInet4)(unsafe.Pointer(ss))
+		return netip.PrefixFrom(netip.AddrFrom4(sa.Addr), bits)
+	case unix.AF_INET6:
+		sa := (*unix.RawSockaddrInet6)(unsafe.Pointer(ss))
+		addr := netip.AddrFrom16(sa.Addr)
+		if addr.Is4In6() && bits <= 32 {
+			return netip.PrefixFrom(addr.Unmap(), bits)
+		}
+		return netip.PrefixFrom(addr, bits)
+	}
+	return netip.Prefix{}
+}
+
+// linux/tcp.h, not in x/sys yet (6.7+)
+const (
+	LINUX_TCP_AO_ADD_KEY  int = 38
+	LINUX_TCP_AO_DEL_KEY  int = 39
+	LINUX_TCP_AO_INFO     int = 40
+	LINUX_TCP_AO_GET_KEYS int = 41
+
+	LINUX_TCP_AO_KEYF_IFINDEX     uint8 = 0x1
+	LINUX_TCP_AO_KEYF_EXCLUDE_OPT uint8 = 0x2
+)
+
+// struct tcp_ao_add
+type tcpAOAdd struct {
+	Addr      [128]byte
+	AlgName   [64]byte
+	Ifindex   int32
+	Flags     uint32 // set_current:1, set_rnext:1
+	Reserved2 uint16
+	Prefix    uint8
+	Sndid     uint8
+	Rcvid     uint8
+	Maclen    uint8
+	Keyflags  uint8
+	Keylen    uint8
+	Key       [80]byte
+}
+
+// struct tcp_ao_del
+type tcpAODel struct {
+	Addr       [128]byte
+	Ifindex    int32
+	Flags      uint32 // set_current:1, set_rnext:1, del_async:1
+	Reserved2  uint16
+	Prefix     uint8
+	Sndid      uint8
+	Rcvid      uint8
+	CurrentKey uint8
+	Rnext      uint8
+	Keyflags   uint8
+}
+
+// struct tcp_ao_info_opt
+type tcpAOInfoOpt struct {
+	Flags          uint32 // set_current:1, set_rnext:1, ao_required:1, set_counters:1, accept_icmps:1
+	Reserved2      uint16
+	CurrentKey     uint8
+	Rnext          uint8
+	PktGood        uint64
+	PktBad         uint64
+	PktKeyNotFound uint64
+	PktAORequired  uint64
+	PktDroppedICMP uint64
+}
+
+// struct tcp_ao_getsockopt
+type tcpAOGetsockopt struct {
+	Addr      [128]byte
+	AlgName   [64]byte
+	Key       [80]byte
+	Nkeys     uint32
+	Flags     uint16 // is_current:1, is_rnext:1, get_all:1
+	Sndid     uint8
+	Rcvid     uint8
+	Ifindex   int32
+	Prefix    uint8
+	Maclen    uint8
+	Keyflags  uint8
+	Reserved2 uint8
+	PktGood   uint64
+	PktBad    uint64
+}
+
+const (
+	aoGetKeysBatch int = 16
+)
+
+var nativeLittleEndian = func() bool {
+	x := uint16(1)
+	return *(*byte)(unsafe.Pointer(&x)) == 1
+}()
+
+// bitfield returns C bitfield member at position pos (0 = first declared) in a width bits unit
+func bitfield(pos uint, width uint) uint32 {
+	if nativeLittleEndian {
+		return 1 << pos
+	}
+	return 1 << (width - 1 - pos)
+}
+
+func aoKeyflags(ifindex int, excludeOptions bool) uint8 {
+	flags := uint8(0)
+	if ifindex != 0 {
+		flags |= LINUX_TCP_AO_KEYF_IFINDEX
+	}
+	if excludeOptions {
+		flags |= LINUX_TCP_AO_KEYF_EXCLUDE_OPT
+	}
+	return flags
+}
+
+func setsockoptAOAddKey(fd int, key AOKey, current, rnext bool) error {
+	opt := tcpAOAdd{
+		Ifindex:  int32(key.Ifindex),
+		Prefix:   uint8(key.Peer.Bits()),
+		Sndid:    key.SendID,
+		Rcvid:    key.RecvID,
+		Maclen:   uint8(key.MACLen),
+		Keyflags: aoKeyflags(key.Ifindex, key.ExcludeOptions),
+		Keylen:   uint8(len(key.Key)),
+	}
+	if err := putSockaddrStorage(&opt.Addr, fd, key.Peer); err != nil {
+		return err
+	}
+	copy(opt.AlgName[:len(opt.AlgName)-1], key.Algorithm)
+	copy(opt.Key[:], key.Key)
+	if current {
+		opt.Flags |= bitfield(0, 32)
+	}
+	if rnext {
+		opt.Flags |= bitfield(1, 32)
+	}
+	buf := (*[unsafe.Sizeof(opt)]byte)(unsafe.Pointer(&opt))[:]
+	return os.NewSyscallError(
+		"setsockopt",
+		unix.SetsockoptString(fd, syscall.IPPROTO_TCP, LINUX_TCP_AO_ADD_KEY, string(buf)),
+	)
+}
+
+func setsockoptAODelKey(fd int, peer netip.Prefix, sendID, recvID uint8, ifindex int) error {
+	opt := tcpAODel{
+		Ifindex:  int32(ifindex),
+		Prefix:   uint8(peer.Bits()),
+		Sndid:    sendID,
+		Rcvid:    recvID,
+		Keyflags: aoKeyflags(ifindex, false),
+	}
+	if err := putSockaddrStorage(&opt.Addr, fd, peer); err != nil {
+		return err
+	}
+	buf := (*[unsafe.Sizeof(opt)]byte)(unsafe.Pointer(&opt))[:]
+	return os.NewSyscallError(
+		"setsockopt",
+		unix.SetsockoptString(fd, syscall.IPPROTO_TCP, LINUX_TCP_AO_DEL_KEY, string(buf)),
+	)
+}
+
+// setsockoptAOInfo changes current (KeyID sent) and/or rnext (KeyID requested from peer), -1 keeps
+func setsockoptAOInfo(fd int, current, rnext int) error {
+	return updateAOInfo(fd, func(opt *tcpAOInfoOpt) {
+		if 0 <= current {
+			opt.Flags |= bitfield(0, 32)
+			opt.CurrentKey = uint8(current)
+		}
+		if 0 <= rnext {
+			opt.Flags |= bitfield(1, 32)
+			opt.Rnext = uint8(rnext)
+		}
+	})
+}
+
+func setsockoptAORequired(fd int, required bool) error {
+	return updateAOInfo(fd, func(opt *tcpAOInfoOpt) {
+		opt.Flags &^= bitfield(2, 32)
+		if required {
+			opt.Flags |= bitfield(2, 32)
+		}
+	})
+}
+
+// kernel always copies ao_required and accept_icmps from tcp_ao_info_opt,
+// so current flags are read first and sent back with the change
+func updateAOInfo(fd int, update func(*tcpAOInfoOpt)) error {
+	cur := tcpAOInfoOpt{}
+	size := uint32(unsafe.Sizeof(cur))
+	if err := getsockopt(fd, syscall.IPPROTO_TCP, LINUX_TCP_AO_INFO, unsafe.Pointer(&cur), &size); err != nil {
+		return err
+	}
+	opt := tcpAOInfoOpt{
+		Flags: cur.Flags & (bitfield(2, 32) | bitfield(4, 32)), // ao_required, accept_icmps
+	}
+	update(&opt)
+	buf := (*[unsafe.Sizeof(opt)]byte)(unsafe.Pointer(&opt))[:]
+	return os.NewSyscallError(
+		"setsockopt",
+		unix.SetsockoptString(fd, syscall.IPPROTO_TCP, LINUX_TCP_AO_INFO, string(buf)),
+	)
+}
+
+func getsockoptAOInfo(fd int) (AOInfo, error) {
+	opt := tcpAOInfoOpt{}
+	size := uint32(unsafe.Sizeof(opt))
+	if err := getsockopt(fd, syscall.IPPROTO_TCP, LINUX_TCP_AO_INFO, unsafe.Pointer(&opt), &size); err != nil {
+		return AOInfo{}, err
+	}
+	return AOInfo{
+		CurrentKey:     opt.CurrentKey,
+		RNextKey:       opt.Rnext,
+		Required:       (opt.Flags & bitfield(2, 32)) != 0,
+		AcceptICMPs:    (opt.Flags & bitfield(4, 32)) != 0,
+		PktGood:        opt.PktGood,
+		PktBad:         opt.PktBad,
+		PktKeyNotFound: opt.PktKeyNotFound,
+		PktAORequired:  opt.PktAORequired,
+		PktDroppedICMP: opt.PktDroppedICMP,
+	}, nil
+}
+
+// kernel fills at most nkeys entries and reports the number written in the first one
+func getsockoptAOKeys(fd int) ([]AOKeyInfo, error) {
+	for limit := aoGetKeysBatch; ; limit *= 2 {
+		opts := make([]tcpAOGetsockopt, limit)
+		opts[0].Nkeys = uint32(limit)
+		opts[0].Flags = uint16(bitfield(2, 16)) // get_all
+		size := uint32(unsafe.Sizeof(opts[0]))
+		if err := getsockopt(fd, syscall.IPPROTO_TCP, LINUX_TCP_AO_GET_KEYS, unsafe.Pointer(&opts[0]), &size); err != nil {
+			return nil, err
+		}
+		n := int(opts[0].Nkeys)
+		if limit <= n {
+			continue
+		}
+		keys := make([]AOKeyInfo, n)
+		for i := 0; i < n; i += 1 {
+			o := &opts[i]
+			keys[i] = AOKeyInfo{
+				Peer:      sockaddrStoragePrefix(&o.Addr, int(o.Prefix)),
+				SendID:    o.Sndid,
+				RecvID:    o.Rcvid,
+				Algorithm: strings.TrimRight(string(o.AlgName[:]), "\x00"),
+				MACLen:    int(o.Maclen),
+				Ifindex:   int(o.Ifindex),
+				Current:   (uint32(o.Flags) & bitfield(0, 16)) != 0,
+				RNext:     (uint32(o.Flags) & bitfield(1, 16)) != 0,
+				PktGood:   o.PktGood,
+				PktBad:    o.PktBad,
+			}
+		}
+		return keys, nil
+	}
+}
+
+// linux/tls.h
+const (
+	LINUX_TLS_TX                       int    = 1
+	LINUX_TLS_RX                       int    = 2
+	LINUX_TLS_SET_RECORD_TYPE          int    = 1
+	LINUX_TLS_GET_RECORD_TYPE          int    = 2
+	LINUX_TLS_CIPHER_AES_GCM_128       uint16 = 51
+	LINUX_TLS_CIPHER_AES_GCM_256       uint16 = 52
+	LINUX_TLS_CIPHER_CHACHA20_POLY1305 uint16 = 54
+)
+
+func setsockoptULP(fd int, name string) error {
+	return os.NewSyscallError(
+		"setsockopt",
+		unix.SetsockoptString(fd, syscall.IPPROTO_TCP, unix.TCP_ULP, name),
+	)
+}
+
+// ktlsCryptoInfo encodes struct tls12_crypto_info_aes_gcm_128/_256 or _chacha20_poly1305,
+// TLS 1.2 AES-GCM explicit nonce continues from the sequence number as crypto/tls does
+func ktlsCryptoInfo(c ktlsCipher) []byte {
+	seq := make([]byte, 8)
+	binary.BigEndian.PutUint64(seq, c.seq)
+
+	cipherType := LINUX_TLS_CIPHER_AES_GCM_128
+	switch c.cipher {
+	case ktlsAESGCM256:
+		cipherType = LINUX_TLS_CIPHER_AES_GCM_256
+	case ktlsChaCha20Poly1305:
+		cipherType = LINUX_TLS_CIPHER_CHACHA20_POLY1305
+	}
+	info := make([]byte, 4, 64)
+	if nativeLittleEndian {
+		binary.LittleEndian.PutUint16(info[0:2], c.version)
+		binary.LittleEndian.PutUint16(info[2:4], cipherType)
+	} else {
+		binary.BigEndian.PutUint16(info[0:2], c.version)
+		binary.BigEndian.PutUint16(info[2:4], cipherType)
+	}
+
+	if c.cipher == ktlsChaCha20Poly1305 {
+		info = append(info, c.iv...)
+		info = append(info, c.key...)
+		return append(info, seq...)
+	}
+	salt, iv := c.iv[0:4], seq
+	if c.version == tls.VersionTLS13 {
+		iv = c.iv[4:12]
+	}
+	info = append(info, iv...)
+	info = append(info, c.key...)
+	info = append(info, salt...)
+	return append(info, seq...)
+}
+
+func setsockoptKTLS(fd int, dir ktlsDirection, c ktlsCipher) error {
+	opt := LINUX_TLS_TX
+	if dir == ktlsRX {
+		opt = LINUX_TLS_RX
+	}
+	return os.NewSyscallError(
+		"setsockopt",
+		unix.SetsockoptString(fd, unix.SOL_TLS, opt, string(ktlsCryptoInfo(c))),
+	)
+}
+
+// records other than application data carry the type in TLS_GET_RECORD_TYPE cmsg
+func recvTLSRecord(fd int, p []byte) (int, uint8, error) {
+	oob := make([]byte, unix.CmsgSpace(1))
+	n, oobn, _, _, err := unix.Recvmsg(fd, p, oob, 0)
+	if err != nil {
+		return 0, 0, err
+	}
+	typ := tlsRecordAppData
+	msgs, err := unix.ParseSocketControlMessage(oob[:oobn])
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, m := range msgs {
+		if int(m.Header.Level) == unix.SOL_TLS && int(m.Header.Type) == LINUX_TLS_GET_RECORD_TYPE && 0 < len(m.Data) {
+			typ = m.Data[0]
+		}
+	}
+	return n, typ, nil
+}
+
+func sendTLSAlert(fd int, alert []byte) error {
+	oob := make([]byte, unix.CmsgSpace(1))
+	h := (*unix.Cmsghdr)(unsafe.Pointer(&oob[0]))
+	h.Level = unix.SOL_TLS
+	h.Type = int32(LINUX_TLS_SET_RECORD_TYPE)
+	h.SetLen(unix.CmsgLen(1))
+	oob[unix.CmsgLen(0)] = tlsRecordAlert
+	_, err := unix.SendmsgN(fd, alert, oob, nil, 0)
+	return err
+}
+
+func setsockoptZeroCopy(fd int, onoff int) error {
+	return os.NewSyscallError(
+		"setsockopt",
+		syscall.SetsockoptInt(fd, syscall.SOL_SOCKET, unix.SO_ZEROCOPY, onoff),
+	)
+}
+
+func getsockoptZeroCopy(fd int) (int, error) {
+	return syscall.GetsockoptInt(fd, syscall.SOL_SOCKET, unix.SO_ZEROCOPY)
+}
+
+func sendZeroCopy(fd int, p []byte) (int, error) {
+	return unix.SendmsgN(fd, p, nil, nil, unix.MSG_ZEROCOPY)
+}
+
+// recvZeroCopyCompletions drains MSG_ERRQUEUE, notifications come as IP_RECVERR/IPV6_RECVERR
+// cmsg of struct sock_extended_err with ee_info..ee_data as the completed id range
+func recvZeroCopyCompletions(fd int) ([]zeroCopyCompletion, error) {
+	completions := make([]zeroCopyCompletion, 0)
+	oob := make([]byte, unix.CmsgSpace(int(unsafe.Sizeof(unix.SockExtendedErr{}))))
+	for {
+		_, oobn, _, _, err := unix.Recvmsg(fd, nil, oob, unix.MSG_ERRQUEUE)
+		if err != nil {
+			if err == syscall.EAGAIN {
+				return completions, nil
+			}
+			return completions, err
+		}
+		msgs, err := unix.ParseSocketControlMessage(oob[:oobn])
+		if err != nil {
+			return completions, err
+		}
+		for _, m := range msgs {
+			recverr := (m.Header.Level == syscall.SOL_IP && m.Header.Type == unix.IP_RECVERR) ||
+				(m.Header.Level == syscall.SOL_IPV6 && m.Header.Type == unix.IPV6_RECVERR)
+			if recverr != true || len(m.Data) < int(unsafe.Sizeof(unix.SockExtendedErr{})) {
+				continue
+			}
+			ee := (*unix.SockExtendedErr)(unsafe.Pointer(&m.Data[0]))
+			if ee.Origin != unix.SO_EE_ORIGIN_ZEROCOPY || ee.Errno != 0 {
+				continue
+			}
+			completions = append(completions, zeroCopyCompletion{
+				lo:     ee.Info,
+				hi:     ee.Data,
+				copied: (ee.Code & unix.SO_EE_CODE_ZEROCOPY_COPIED) != 0,
+			})
+		}
+	}
+}
+
+// pollErrQueue waits up to timeout for the error queue, regardless of the deadlines of the conn
+func pollErrQueue(fd int, timeout time.Duration) error {
+	fds := []unix.PollFd{{Fd: int32(fd)}} // POLLERR is always reported
+	if _, err := unix.Poll(fds, int(timeout/time.Millisecond)); err != nil && err != syscall.EINTR {
+		return os.NewSyscallError("poll", err)
+	}
+	return nil
+}
+
+// linux/tcp.h struct tcp_zerocopy_receive (5.11+ size), older kernels read a prefix
+type tcpZeroCopyReceive struct {
+	Address        uint64
+	Length         uint32
+	RecvSkipHint   uint32
+	Inq            uint32
+	Err            int32
+	CopybufAddress uint64
+	CopybufLen     int32
+	Flags          uint32
+	MsgControl     uint64
+	MsgControllen  uint64
+	MsgFlags       uint32
+	Reserved       uint32
+}
+
+func mmapZeroCopyReceive(fd int, size int) ([]byte, error) {
+	b, err := unix.Mmap(fd, 0, size, unix.PROT_READ, unix.MAP_SHARED)
+	if err != nil {
+		return nil, os.NewSyscallError("mmap", err)
+	}
+	return b, nil
+}
+
+func munmapZeroCopyReceive(b []byte) error {
+	return unix.Munmap(b)
+}
+
+func getsockoptZeroCopyReceive(fd int, mapping []byte) (int, int, int, error) {
+	zc := tcpZeroCopyReceive{
+		Address: uint64(uintptr(unsafe.Pointer(&mapping[0]))),
+		Length:  uint32(len(mapping)),
+	}
+	size := uint32(unsafe.Sizeof(zc))
+	if err := getsockopt(fd, syscall.IPPROTO_TCP, unix.TCP_ZEROCOPY_RECEIVE, unsafe.Pointer(&zc), &size); err != nil {
+		return 0, 0, 0, err
+	}
+	if zc.Err != 0 {
+		return 0, 0, 0, syscall.Errno(-zc.Err)
+	}
+	return int(zc.Length), int(zc.RecvSkipHint), int(zc.Inq), nil
+}
@@ -381,3 +381,34 @@ func TestReusePort(t *testing.T) {
 	svr1.Wait()
 	svr2.Wait()
 }
+
+func TestSetDeferAcceptTimeout(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen err: %+v", err)
+	}
+	defer listener.Close()
+
+	tt := []struct {
+		timeout time.Duration
+		expect  time.Duration
+	}{
+		{1 * time.Second, 1 * time.Second},
+		{500 * time.Millisecond, 1 * time.Second},
+		{3 * time.Second, 3 * time.Second},
+		{5 * time.Second, 7 * time.Second}, // 3 retransmissions = 1+2+4
+		{0, 0},
+	}
+	for _, tc := range tt {
+		if err := SetDeferAcceptTimeout(listener, tc.timeout); err != nil {
+			t.Fatalf("defer_accept set err: %+v", err)
+		}
+		d, err := GetDeferAcceptTimeout(listener)
+		if err != nil {
+			t.Fatalf("defer_accept get err: %+v", err)
+		}
+		if d != tc.expect {
+			t.Errorf("defer_accept %s expect %s, actual %s", tc.timeout, tc.expect, d)
+		}
+	}
+}
@@ -4,6 +4,8 @@
 package tcpoption
 
 import (
+	"net/netip"
+	"syscall"
 	"time"
 )
 
@@ -11,6 +13,10 @@ func setsockoptLingerTimeout(fd int, d time.Duration) error {
 	return nil // not support
 }
 
+func getsockoptLingerTimeout(fd int) (int, error) {
+	return 0, nil // not support
+}
+
 func setsockoptKeepAliveIdle(fd int, sec int) error {
 	return nil // not support
 }
@@ -51,14 +57,46 @@ func getsockoptFastOpenConnect(fd int) (int, error) {
 	return 0, nil // not support
 }
 
+func setsockoptFastOpenKey(fd int, keys []byte) error {
+	return nil // not support
+}
+
+func getsockoptFastOpenKey(fd int) ([]byte, error) {
+	return nil, nil // not support
+}
+
+func setsockoptFastOpenNoCookie(fd int, onoff int) error {
+	return nil // not support
+}
+
+func getsockoptFastOpenNoCookie(fd int) (int, error) {
+	return 0, nil // not support
+}
+
+func connectFastOpen(fd int, address string, first []byte) (int, error) {
+	return 0, nil // not support
+}
+
+func getsockoptFastOpenResult(fd int) (FastOpenResult, bool, error) {
+	return FastOpenResult{Fallback: true}, true, nil // not support
+}
+
 func setsockoptQuickACK(fd int, onoff int) error {
 	return nil // not support
 }
 
-func setsockoptDeferAccept(fd int, onoff int) error {
+func getsockoptQuickACK(fd int) (int, error) {
+	return 0, nil // not support
+}
+
+func setsockoptDeferAccept(fd int, sec int) error {
 	return nil // not support
 }
 
+func getsockoptDeferAccept(fd int) (int, error) {
+	return 0, nil // not support
+}
+
 func setsockoptReuseAddr(fd int, onoff int) error {
 	return nil // not support
 }
@@ -74,3 +112,391 @@ func setsockoptReusePort(fd int, onoff int) error {
 func getsockoptReusePort(fd int) (int, error) {
 	return 0, nil // not support
 }
+
+func getsockoptTCPState(fd int) (State, error) {
+	return StateUnknown, nil // not support
+}
+
+func getsockoptError(fd int) (int, error) {
+	return 0, nil // not support
+}
+
+func recvPeek(fd int) (int, error) {
+	return 0, syscall.EAGAIN // not support
+}
+
+func getsockoptNoDelay(fd int) (int, error) {
+	return 0, nil // not support
+}
+
+func setsockoptCork(fd int, onoff int) error {
+	return nil // not support
+}
+
+func getsockoptCork(fd int) (int, error) {
+	return 0, nil // not support
+}
+
+func setsockoptSaveSYN(fd int, onoff int) error {
+	return nil // not support
+}
+
+func getsockoptSaveSYN(fd int) (int, error) {
+	return 0, nil // not support
+}
+
+func getsockoptSavedSYN(fd int) ([]byte, error) {
+	return nil, nil // not support
+}
+
+func setsockoptMaxSeg(fd int, mss int) error {
+	return nil // not support
+}
+
+func getsockoptMaxSeg(fd int) (int, error) {
+	return 0, nil // not support
+}
+
+func setsockoptWindowClamp(fd int, bytes int) error {
+	return nil // not support
+}
+
+func getsockoptWindowClamp(fd int) (int, error) {
+	return 0, nil // not support
+}
+
+func getsockoptMSSInfo(fd int) (MSSInfo, error) {
+	return MSSInfo{}, nil // not support
+}
+
+func setsockoptSynCount(fd int, count int) error {
+	return nil // not support
+}
+
+func getsockoptSynCount(fd int) (int, error) {
+	return 0, nil // not support
+}
+
+func setsockoptThinLinearTimeouts(fd int, onoff int) error {
+	return nil // not support
+}
+
+func getsockoptThinLinearTimeouts(fd int) (int, error) {
+	return 0, nil // not support
+}
+
+func setsockoptUserTimeout(fd int, d time.Duration) error {
+	return nil // not support
+}
+
+func getsockoptUserTimeout(fd int) (int, error) {
+	return 0, nil // not support
+}
+
+func setsockoptMaxPacingRate(fd int, bytesPerSec uint64) error {
+	return nil // not support
+}
+
+func getsockoptMaxPacingRate(fd int) (uint64, error) {
+	return 0, nil // not support
+}
+
+func getsockoptPacingRate(fd int) (uint64, error) {
+	return 0, nil // not support
+}
+
+func getsockoptBDPSample(fd int) (time.Duration, uint64, error) {
+	return 0, 0, nil // not support
+}
+
+func setsockoptSendBuffer(fd int, bytes int) error {
+	return nil // not support
+}
+
+func getsockoptSendBuffer(fd int) (int, error) {
+	return 0, nil // not support
+}
+
+func setsockoptRecvBuffer(fd int, bytes int) error {
+	return nil // not support
+}
+
+func getsockoptRecvBuffer(fd int) (int, error) {
+	return 0, nil // not support
+}
+
+func setsockoptBufLock(fd int, lock int) error {
+	return nil // not support
+}
+
+func getsockoptBufLock(fd int) (int, error) {
+	return 0, nil // not support
+}
+
+func setsockoptNotSentLowat(fd int, bytes int) error {
+	return nil // not support
+}
+
+func getsockoptNotSentLowat(fd int) (int, error) {
+	return 0, nil // not support
+}
+
+func autotuneMax() (int, int) {
+	return 0, 0 // not support
+}
+
+func pinBufferMax() (int, int) {
+	return 0, 0 // not support
+}
+
+func bufferRequest(size int) int {
+	return size
+}
+
+func setsockoptSendBufferForce(fd int, bytes int) error {
+	return syscall.ENOPROTOOPT // not support
+}
+
+func setsockoptRecvBufferForce(fd int, bytes int) error {
+	return syscall.ENOPROTOOPT // not support
+}
+
+func setsockoptTOS(fd int, tos int) error {
+	return nil // not support
+}
+
+func getsockoptTOS(fd int) (int, error) {
+	return 0, nil // not support
+}
+
+func setsockoptTrafficClass(fd int, tclass int) error {
+	return nil // not support
+}
+
+func getsockoptTrafficClass(fd int) (int, error) {
+	return 0, nil // not support
+}
+
+func setsockoptMark(fd int, mark uint32) error {
+	return nil // not support
+}
+
+func getsockoptMark(fd int) (uint32, error) {
+	return 0, nil // not support
+}
+
+func setsockoptPriority(fd int, priority int) error {
+	return nil // not support
+}
+
+func getsockoptPriority(fd int) (int, error) {
+	return 0, nil // not support
+}
+
+func setsockoptBindToDevice(fd int, name string) error {
+	return nil // not support
+}
+
+func getsockoptBindToDevice(fd int) (string, error) {
+	return "", nil // not support
+}
+
+func setsockoptBindToIfindex(fd int, ifindex int) error {
+	return nil // not support
+}
+
+func getsockoptBindToIfindex(fd int) (int, error) {
+	return 0, nil // not support
+}
+
+func setsockoptBindAddressNoPort(fd int, enable int) error {
+	return nil // not support
+}
+
+func getsockoptBindAddressNoPort(fd int) (int, error) {
+	return 0, nil // not support
+}
+
+func setsockoptLocalPortRange(fd int, r uint32) error {
+	return nil // not support
+}
+
+func getsockoptLocalPortRange(fd int) (uint32, error) {
+	return 0, nil // not support
+}
+
+func setsockoptTransparent(fd int, enable int) error {
+	return nil // not support
+}
+
+func getsockoptTransparent(fd int) (int, error) {
+	return 0, nil // not support
+}
+
+func setsockoptIPv6Transparent(fd int, enable int) error {
+	return nil // not support
+}
+
+func getsockoptIPv6Transparent(fd int) (int, error) {
+	return 0, nil // not support
+}
+
+func setsockoptFreeBind(fd int, enable int) error {
+	return nil // not support
+}
+
+func getsockoptFreeBind(fd int) (int, error) {
+	return 0, nil // not support
+}
+
+func setsockoptIPv6FreeBind(fd int, enable int) error {
+	return nil // not support
+}
+
+func getsockoptIPv6FreeBind(fd int) (int, error) {
+	return 0, nil // not support
+}
+
+func setsockoptV6Only(fd int, enable int) error {
+	return nil // not support
+}
+
+func getsockoptV6Only(fd int) (int, error) {
+	return 0, nil // not support
+}
+
+func setsockoptMTUDiscover(fd int, mode int) error {
+	return nil // not support
+}
+
+func getsockoptMTUDiscover(fd int) (int, error) {
+	return 0, nil // not support
+}
+
+func setsockoptIPv6MTUDiscover(fd int, mode int) error {
+	return nil // not support
+}
+
+func getsockoptIPv6MTUDiscover(fd int) (int, error) {
+	return 0, nil // not support
+}
+
+func getsockoptMTU(fd int) (int, error) {
+	return 0, nil // not support
+}
+
+func getsockoptIPv6MTU(fd int) (int, error) {
+	return 0, nil // not support
+}
+
+func getsockoptTCPInfoPMTU(fd int) (int, error) {
+	return 0, nil // not support
+}
+
+func setsockoptTTL(fd int, ttl int) error {
+	return nil // not support
+}
+
+func getsockoptTTL(fd int) (int, error) {
+	return 0, nil // not support
+}
+
+func setsockoptHopLimit(fd int, hops int) error {
+	return nil // not support
+}
+
+func getsockoptHopLimit(fd int) (int, error) {
+	return 0, nil // not support
+}
+
+func setsockoptMinTTL(fd int, ttl int) error {
+	return nil // not support
+}
+
+func getsockoptMinTTL(fd int) (int, error) {
+	return 0, nil // not support
+}
+
+func setsockoptMinHopCount(fd int, hops int) error {
+	return nil // not support
+}
+
+func getsockoptMinHopCount(fd int) (int, error) {
+	return 0, nil // not support
+}
+
+func setsockoptMD5Key(fd int, peer netip.Prefix, key []byte, ifindex int) error {
+	return syscall.ENOPROTOOPT // not support
+}
+
+func setsockoptAOAddKey(fd int, key AOKey, current, rnext bool) error {
+	return syscall.ENOPROTOOPT // not support
+}
+
+func setsockoptAODelKey(fd int, peer netip.Prefix, sendID, recvID uint8, ifindex int) error {
+	return syscall.ENOPROTOOPT // not support
+}
+
+func setsockoptAOInfo(fd int, current, rnext int) error {
+	return syscall.ENOPROTOOPT // not support
+}
+
+func setsockoptAORequired(fd int, required bool) error {
+	return syscall.ENOPROTOOPT // not support
+}
+
+func getsockoptAOInfo(fd int) (AOInfo, error) {
+	return AOInfo{}, syscall.ENOPROTOOPT // not support
+}
+
+func getsockoptAOKeys(fd int) ([]AOKeyInfo, error) {
+	return nil, syscall.ENOPROTOOPT // not support
+}
+
+func setsockoptULP(fd int, name string) error {
+	return syscall.ENOPROTOOPT // not support
+}
+
+func setsockoptKTLS(fd int, dir ktlsDirection, c ktlsCipher) error {
+	return syscall.ENOPROTOOPT // not support
+}
+
+func recvTLSRecord(fd int, p []byte) (int, uint8, error) {
+	return 0, 0, syscall.ENOPROTOOPT // not support
+}
+
+func sendTLSAlert(fd int, alert []byte) error {
+	return syscall.ENOPROTOOPT // not support
+}
+
+func setsockoptZeroCopy(fd int, onoff int) error {
+	return syscall.ENOPROTOOPT // not support
+}
+
+func getsockoptZeroCopy(fd int) (int, error) {
+	return 0, nil // not support
+}
+
+func sendZeroCopy(fd int, p []byte) (int, error) {
+	return 0, syscall.ENOPROTOOPT // not support
+}
+
+func recvZeroCopyCompletions(fd int) ([]zeroCopyCompletion, error) {
+	return nil, nil // not support
+}
+
+func pollErrQueue(fd int, timeout time.Duration) error {
+	return syscall.ENOPROTOOPT // not support
+}
+
+func mmapZeroCopyReceive(fd int, size int) ([]byte, error) {
+	return nil, syscall.ENODEV // not support
+}
+
+func munmapZeroCopyReceive(b []byte) error {
+	return nil // not support
+}
+
+func getsockoptZeroCopyReceive(fd int, mapping []byte) (int, int, int, error) {
+	return 0, 0, 0, syscall.ENOPROTOOPT // not support
+}
@@ -0,0 +1,241 @@
+package tcpoption
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+)
+
+type PortRange struct {
+	Low  uint16
+	High uint16
+}
+
+func (r PortRange) IsZero() bool {
+	return r.Low == 0 && r.High == 0
+}
+
+// IP_LOCAL_PORT_RANGE value, lower 16 bits low and upper 16 bits high
+func (r PortRange) value() uint32 {
+	return uint32(r.High)<<16 | uint32(r.Low)
+}
+
+// SetBindAddressNoPortFd defers port allocation of bind(ip, 0) to connect,
+// so the same ephemeral port can be reused per 4-tuple. call before bind.
+func SetBindAddressNoPortFd(fd int, enable bool) error {
+	return setsockoptBindAddressNoPort(fd, IntBool(enable))
+}
+
+func GetBindAddressNoPortFd(fd int) (bool, error) {
+	v, err := getsockoptBindAddressNoPort(fd)
+	if err != nil {
+		return false, err
+	}
+	return v != 0, nil
+}
+
+// SetLocalPortRangeFd narrows ip_local_port_range for fd, requires kernel 6.3+ (ENOPROTOOPT otherwise).
+// call before bind or connect.
+func SetLocalPortRangeFd(fd int, r PortRange) error {
+	return setsockoptLocalPortRange(fd, r.value())
+}
+
+func GetLocalPortRange(conn net.Conn) (PortRange, error) {
+	if c, ok := conn.(*net.TCPConn); ok {
+		r := PortRange{}
+		if err := getFd(c, func(fd int) error {
+			v, err := GetLocalPortRangeFd(fd)
+			if err != nil {
+				return err
+			}
+			r = v
+			return nil
+		}); err != nil {
+			return PortRange{}, err
+		}
+		return r, nil
+	}
+	return PortRange{}, nil
+}
+
+func GetLocalPortRangeFd(fd int) (PortRange, error) {
+	v, err := getsockoptLocalPortRange(fd)
+	if err != nil {
+		return PortRange{}, err
+	}
+	return PortRange{Low: uint16(v), High: uint16(v >> 16)}, nil
+}
+
+type SourceSelect uint8
+
+const (
+	SourceRoundRobin SourceSelect = iota
+	SourceHash                    // same destination always uses the same source
+)
+
+type SourceUsage struct {
+	IP     net.IP
+	Dials  uint64 // established connections
+	Errors uint64 // failed attempts, EADDRNOTAVAIL moves on to the next source
+}
+
+type source struct {
+	dials  uint64
+	errors uint64
+	ip     net.IP
+}
+
+// SourcePool dials from one of several local addresses with IP_BIND_ADDRESS_NO_PORT,
+// ephemeral ports are then allocated per 4-tuple instead of per source ip.
+// cfg.LocalPortRange narrows the ephemeral range on kernel 6.3+, older kernels keep the sysctl range.
+type SourcePool struct {
+	sources []*source
+	mode    SourceSelect
+	cfg     Config
+	next    uint64
+}
+
+func NewSourcePool(sources []net.IP, mode SourceSelect, cfg Config) (*SourcePool, error) {
+	if len(sources) < 1 {
+		return nil, fmt.Errorf("source pool requires at least one address")
+	}
+	p := &SourcePool{
+		sources: make([]*source, 0, len(sources)),
+		mode:    mode,
+		cfg:     cfg,
+	}
+	for _, ip := range sources {
+		if ip.To16() == nil || ip.IsUnspecified() {
+			return nil, fmt.Errorf("invalid source address: %s", ip)
+		}
+		p.sources = append(p.sources, &source{ip: ip})
+	}
+	p.cfg.BindAddressNoPort = true
+	return p, nil
+}
+
+func (p *SourcePool) Usage() []SourceUsage {
+	usage := make([]SourceUsage, len(p.sources))
+	for i, s := range p.sources {
+		usage[i] = SourceUsage{
+			IP:     s.ip,
+			Dials:  atomic.LoadUint64(&s.dials),
+			Errors: atomic.LoadUint64(&s.errors),
+		}
+	}
+	return usage
+}
+
+func (p *SourcePool) Dial(network, address string) (net.Conn, error) {
+	return p.DialContext(context.Background(), network, address)
+}
+
+// DialContext tries sources of the destination family starting at the selected one,
+// moving on while bind/connect fails with EADDRNOTAVAIL.
+func (p *SourcePool) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	raddr, err := p.resolve(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	candidates := p.candidates(raddr)
+	if len(candidates) < 1 {
+		return nil, fmt.Errorf("no source address for %s", raddr)
+	}
+
+	var lastErr error
+	for _, s := range candidates {
+		conn, err := p.dial(ctx, network, s, raddr)
+		if err == nil {
+			atomic.AddUint64(&s.dials, 1)
+			return conn, nil
+		}
+		atomic.AddUint64(&s.errors, 1)
+		lastErr = err
+		if errors.Is(err, syscall.EADDRNOTAVAIL) != true {
+			return nil, err
+		}
+	}
+	return nil, lastErr
+}
+
+func (p *SourcePool) dial(ctx context.Context, network string, s *source, raddr *net.TCPAddr) (net.Conn, error) {
+	d := Dialer(p.cfg)
+	d.LocalAddr = &net.TCPAddr{IP: s.ip}
+	conn, err := d.DialContext(ctx, network, raddr.String())
+	if err != nil {
+		return nil, err
+	}
+	if err := Set(conn, p.cfg); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (p *SourcePool) candidates(raddr *net.TCPAddr) []*source {
+	matched := p.candidatesOf(raddr.IP)
+	if len(matched) < 2 {
+		return matched
+	}
+
+	start := 0
+	switch p.mode {
+	case SourceHash:
+		h := fnv.New32a()
+		h.Write([]byte(raddr.String()))
+		start = int(h.Sum32() % uint32(len(matched)))
+	default:
+		start = int(atomic.AddUint64(&p.next, 1) % uint64(len(matched)))
+	}
+	ordered := make([]*source, 0, len(matched))
+	ordered = append(ordered, matched[start:]...)
+	ordered = append(ordered, matched[:start]...)
+	return ordered
+}
+
+func (p *SourcePool) resolve(ctx context.Context, network, address string) (*net.TCPAddr, error) {
+	host, service, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	port, err := net.DefaultResolver.LookupPort(ctx, network, service)
+	if err != nil {
+		return nil, err
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return &net.TCPAddr{IP: ip, Port: port}, nil
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range addrs {
+		if network == "tcp4" && a.IP.To4() == nil {
+			continue
+		}
+		if network == "tcp6" && a.IP.To4() != nil {
+			continue
+		}
+		if len(p.candidatesOf(a.IP)) < 1 {
+			continue
+		}
+		return &net.TCPAddr{IP: a.IP, Port: port, Zone: a.Zone}, nil
+	}
+	return nil, fmt.Errorf("no address of %s matches source pool family", net.JoinHostPort(host, strconv.Itoa(port)))
+}
+
+func (p *SourcePool) candidatesOf(ip net.IP) []*source {
+	ipv4 := ip.To4() != nil
+	matched := make([]*source, 0, len(p.sources))
+	for _, s := range p.sources {
+		if (s.ip.To4() != nil) == ipv4 {
+			matched = append(matched, s)
+		}
+	}
+	return matched
+}
@@ -0,0 +1,182 @@
+package tcpoption
+
+import (
+	"context"
+	"net"
+	"testing"
+)
+
+func acceptLoop(t *testing.T, listener net.Listener) chan net.Conn {
+	accepted := make(chan net.Conn, 16)
+	go func() {
+		defer close(accepted)
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- conn
+		}
+	}()
+	return accepted
+}
+
+func TestSourcePool(t *testing.T) {
+	sources := []net.IP{
+		net.ParseIP("127.0.0.2"),
+		net.ParseIP("127.0.0.3"),
+		net.ParseIP("127.0.0.4"),
+	}
+
+	t.Run("roundrobin", func(tt *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			tt.Fatalf("listen err: %+v", err)
+		}
+		defer listener.Close()
+		accepted := acceptLoop(tt, listener)
+
+		pool, err := NewSourcePool(sources, SourceRoundRobin, Config{})
+		if err != nil {
+			tt.Fatalf("pool err: %+v", err)
+		}
+		seen := map[string]int{}
+		for i := 0; i < 6; i += 1 {
+			conn, err := pool.DialContext(context.TODO(), "tcp", listener.Addr().String())
+			if err != nil {
+				tt.Fatalf("dial err: %+v", err)
+			}
+			defer conn.Close()
+
+			server := <-accepted
+			defer server.Close()
+			seen[server.RemoteAddr().(*net.TCPAddr).IP.String()] += 1
+
+			noPort := false
+			if err := getFd(conn.(*net.TCPConn), func(fd int) error {
+				v, err := GetBindAddressNoPortFd(fd)
+				noPort = v
+				return err
+			}); err != nil {
+				tt.Fatalf("bind_address_no_port get err: %+v", err)
+			}
+			if noPort != true {
+				tt.Errorf("IP_BIND_ADDRESS_NO_PORT must be set")
+			}
+		}
+		for _, ip := range sources {
+			if seen[ip.String()] != 2 {
+				tt.Errorf("%s expect 2 conns, actual:%d (%v)", ip, seen[ip.String()], seen)
+			}
+		}
+		for _, u := range pool.Usage() {
+			if u.Dials != 2 || u.Errors != 0 {
+				tt.Errorf("%s expect dials=2 errors=0, actual:%+v", u.IP, u)
+			}
+		}
+	})
+	t.Run("hash", func(tt *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			tt.Fatalf("listen err: %+v", err)
+		}
+		defer listener.Close()
+		accepted := acceptLoop(tt, listener)
+
+		pool, err := NewSourcePool(sources, SourceHash, Config{})
+		if err != nil {
+			tt.Fatalf("pool err: %+v", err)
+		}
+		seen := map[string]int{}
+		for i := 0; i < 4; i += 1 {
+			conn, err := pool.DialContext(context.TODO(), "tcp", listener.Addr().String())
+			if err != nil {
+				tt.Fatalf("dial err: %+v", err)
+			}
+			defer conn.Close()
+
+			server := <-accepted
+			defer server.Close()
+			seen[server.RemoteAddr().(*net.TCPAddr).IP.String()] += 1
+		}
+		if len(seen) != 1 {
+			tt.Errorf("same destination must use same source: %v", seen)
+		}
+	})
+	t.Run("port_range", func(tt *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			tt.Fatalf("listen err: %+v", err)
+		}
+		defer listener.Close()
+		accepted := acceptLoop(tt, listener)
+
+		r := PortRange{Low: 40100, High: 40109}
+		pool, err := NewSourcePool(sources[:1], SourceRoundRobin, Config{LocalPortRange: r})
+		if err != nil {
+			tt.Fatalf("pool err: %+v", err)
+		}
+		conn, err := pool.DialContext(context.TODO(), "tcp", listener.Addr().String())
+		if err != nil {
+			tt.Fatalf("dial err: %+v", err)
+		}
+		defer conn.Close()
+		server := <-accepted
+		defer server.Close()
+
+		actual, err := GetLocalPortRange(conn)
+		if err != nil {
+			tt.Skipf("IP_LOCAL_PORT_RANGE not supported: %+v", err)
+		}
+		if actual != r {
+			tt.Errorf("range %+v, actual:%+v", r, actual)
+		}
+		port := conn.LocalAddr().(*net.TCPAddr).Port
+		if port < int(r.Low) || int(r.High) < port {
+			tt.Errorf("local port %d out of %+v", port, r)
+		}
+	})
+	t.Run("fallback", func(tt *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			tt.Fatalf("listen err: %+v", err)
+		}
+		defer listener.Close()
+		accepted := acceptLoop(tt, listener)
+
+		// TEST-NET-1, not assigned on this host
+		pool, err := NewSourcePool([]net.IP{net.ParseIP("192.0.2.1"), net.ParseIP("127.0.0.2")}, SourceRoundRobin, Config{})
+		if err != nil {
+			tt.Fatalf("pool err: %+v", err)
+		}
+		for i := 0; i < 2; i += 1 {
+			conn, err := pool.DialContext(context.TODO(), "tcp", listener.Addr().String())
+			if err != nil {
+				tt.Fatalf("dial err: %+v", err)
+			}
+			defer conn.Close()
+			server := <-accepted
+			defer server.Close()
+
+			if ip := server.RemoteAddr().(*net.TCPAddr).IP.String(); ip != "127.0.0.2" {
+				tt.Errorf("must fall back to 127.0.0.2, actual:%s", ip)
+			}
+		}
+		usage := pool.Usage()
+		if usage[0].Dials != 0 || usage[0].Errors != 1 {
+			tt.Errorf("192.0.2.1 expect dials=0 errors=1: %+v", usage)
+		}
+		if usage[1].Dials != 2 {
+			tt.Errorf("127.0.0.2 expect 2 dials: %+v", usage)
+		}
+	})
+	t.Run("family", func(tt *testing.T) {
+		pool, err := NewSourcePool(sources, SourceRoundRobin, Config{})
+		if err != nil {
+			tt.Fatalf("pool err: %+v", err)
+		}
+		if _, err := pool.DialContext(context.TODO(), "tcp", "[::1]:80"); err == nil {
+			tt.Errorf("ipv6 destination without ipv6 source must fail")
+		}
+	})
+}
@@ -0,0 +1,300 @@
+package tcpoption
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// TCP option kinds
+const (
+	tcpOptEOL       uint8 = 0
+	tcpOptNOP       uint8 = 1
+	tcpOptMSS       uint8 = 2
+	tcpOptWS        uint8 = 3
+	tcpOptSACKOK    uint8 = 4
+	tcpOptSACK      uint8 = 5
+	tcpOptTimestamp uint8 = 8
+)
+
+// SetSaveSYN keeps the SYN of each connection accepted by listener, read it with SavedSYN.
+func SetSaveSYN(listener net.Listener, enable bool) error {
+	if l, ok := listener.(*net.TCPListener); ok {
+		return getFd(l, func(fd int) error {
+			return setsockoptSaveSYN(fd, IntBool(enable))
+		})
+	}
+	return nil
+}
+
+func SetSaveSYNFd(fd int, enable bool) error {
+	return setsockoptSaveSYN(fd, IntBool(enable))
+}
+
+// SavedSYN returns IP and TCP headers of the SYN that opened conn.
+// kernel releases the saved SYN once read, subsequent calls return empty.
+func SavedSYN(conn net.Conn) ([]byte, error) {
+	if c, ok := conn.(*net.TCPConn); ok {
+		var syn []byte
+		if err := getFd(c, func(fd int) error {
+			b, err := SavedSYNFd(fd)
+			if err != nil {
+				return err
+			}
+			syn = b
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+		return syn, nil
+	}
+	return nil, nil
+}
+
+func SavedSYNFd(fd int) ([]byte, error) {
+	return getsockoptSavedSYN(fd)
+}
+
+type SYN struct {
+	IPVersion     int
+	SrcIP         net.IP
+	DstIP         net.IP
+	TTL           uint8 // hop limit on IPv6
+	InitialTTL    uint8
+	DontFragment  bool
+	IPID          uint16
+	FlowLabel     uint32
+	ECN           bool
+	IPOptionsLen  int
+	SrcPort       uint16
+	DstPort       uint16
+	Window        uint16
+	MSS           uint16
+	WindowScale   int // -1 if absent
+	SACKPermitted bool
+	Timestamp     bool
+	TSval         uint32
+	TSecr         uint32
+	OptionLayout  []string
+	Quirks        []string
+	PayloadLen    int
+}
+
+// ParseSYN decodes the headers returned by SavedSYN
+func ParseSYN(b []byte) (*SYN, error) {
+	if len(b) < 1 {
+		return nil, fmt.Errorf("syn too short: %d bytes", len(b))
+	}
+	syn := &SYN{WindowScale: -1}
+
+	var tcp []byte
+	switch b[0] >> 4 {
+	case 4:
+		t, err := syn.parseIPv4(b)
+		if err != nil {
+			return nil, err
+		}
+		tcp = t
+	case 6:
+		t, err := syn.parseIPv6(b)
+		if err != nil {
+			return nil, err
+		}
+		tcp = t
+	default:
+		return nil, fmt.Errorf("unknown ip version: %d", b[0]>>4)
+	}
+	if err := syn.parseTCP(tcp); err != nil {
+		return nil, err
+	}
+	syn.InitialTTL = guessInitialTTL(syn.TTL)
+	return syn, nil
+}
+
+func (s *SYN) parseIPv4(b []byte) ([]byte, error) {
+	if len(b) < 20 {
+		return nil, fmt.Errorf("ipv4 header too short: %d bytes", len(b))
+	}
+	ihl := int(b[0]&0x0f) * 4
+	if ihl < 20 || len(b) < ihl {
+		return nil, fmt.Errorf("invalid ipv4 header length: %d", ihl)
+	}
+	if b[9] != 6 {
+		return nil, fmt.Errorf("not tcp: protocol %d", b[9])
+	}
+	s.IPVersion = 4
+	s.ECN = (b[1] & 0x03) != 0
+	s.IPID = binary.BigEndian.Uint16(b[4:6])
+	s.DontFragment = (b[6] & 0x40) != 0
+	s.TTL = b[8]
+	s.SrcIP = net.IP(append([]byte(nil), b[12:16]...))
+	s.DstIP = net.IP(append([]byte(nil), b[16:20]...))
+	s.IPOptionsLen = ihl - 20
+
+	if s.DontFragment {
+		s.Quirks = append(s.Quirks, "df")
+		if s.IPID != 0 {
+			s.Quirks = append(s.Quirks, "id+")
+		}
+	} else {
+		if s.IPID == 0 {
+			s.Quirks = append(s.Quirks, "id-")
+		}
+	}
+	return b[ihl:], nil
+}
+
+func (s *SYN) parseIPv6(b []byte) ([]byte, error) {
+	if len(b) < 40 {
+		return nil, fmt.Errorf("ipv6 header too short: %d bytes", len(b))
+	}
+	s.IPVersion = 6
+	tclass := ((b[0] & 0x0f) << 4) | (b[1] >> 4)
+	s.ECN = (tclass & 0x03) != 0
+	s.FlowLabel = binary.BigEndian.Uint32(b[0:4]) & 0x000fffff
+	s.TTL = b[7]
+	s.SrcIP = net.IP(append([]byte(nil), b[8:24]...))
+	s.DstIP = net.IP(append([]byte(nil), b[24:40]...))
+	if s.FlowLabel != 0 {
+		s.Quirks = append(s.Quirks, "flow")
+	}
+
+	next, rest := b[6], b[40:]
+	for {
+		switch next {
+		case 6:
+			return rest, nil
+		case 0, 43, 60: // hop-by-hop, routing, destination options
+			if len(rest) < 8 {
+				return nil, fmt.Errorf("ipv6 extension header too short")
+			}
+			size := (int(rest[1]) + 1) * 8
+			if len(rest) < size {
+				return nil, fmt.Errorf("ipv6 extension header too short")
+			}
+			next, rest = rest[0], rest[size:]
+			s.IPOptionsLen += size
+		case 44: // fragment
+			if len(rest) < 8 {
+				return nil, fmt.Errorf("ipv6 fragment header too short")
+			}
+			next, rest = rest[0], rest[8:]
+			s.IPOptionsLen += 8
+		default:
+			return nil, fmt.Errorf("not tcp: next header %d", next)
+		}
+	}
+}
+
+func (s *SYN) parseTCP(b []byte) error {
+	if len(b) < 20 {
+		return fmt.Errorf("tcp header too short: %d bytes", len(b))
+	}
+	doff := int(b[12]>>4) * 4
+	if doff < 20 || len(b) < doff {
+		return fmt.Errorf("invalid tcp header length: %d", doff)
+	}
+	s.SrcPort = binary.BigEndian.Uint16(b[0:2])
+	s.DstPort = binary.BigEndian.Uint16(b[2:4])
+	s.Window = binary.BigEndian.Uint16(b[14:16])
+	s.PayloadLen = len(b) - doff
+	if (b[13] & 0xc0) != 0 { // ECE, CWR
+		s.Quirks = append(s.Quirks, "ecn")
+	}
+
+	opts := b[20:doff]
+	for i := 0; i < len(opts); {
+		kind := opts[i]
+		switch kind {
+		case tcpOptEOL:
+			s.OptionLayout = append(s.OptionLayout, "eol+"+strconv.Itoa(len(opts)-i-1))
+			return nil
+		case tcpOptNOP:
+			s.OptionLayout = append(s.OptionLayout, "nop")
+			i += 1
+			continue
+		}
+		if len(opts) < i+2 || opts[i+1] < 2 || len(opts) < i+int(opts[i+1]) {
+			s.Quirks = append(s.Quirks, "bad")
+			return nil
+		}
+		size := int(opts[i+1])
+		val := opts[i+2 : i+size]
+		switch {
+		case kind == tcpOptMSS && size == 4:
+			s.OptionLayout = append(s.OptionLayout, "mss")
+			s.MSS = binary.BigEndian.Uint16(val)
+		case kind == tcpOptWS && size == 3:
+			s.OptionLayout = append(s.OptionLayout, "ws")
+			s.WindowScale = int(val[0])
+			if 14 < s.WindowScale {
+				s.Quirks = append(s.Quirks, "exws")
+			}
+		case kind == tcpOptSACKOK && size == 2:
+			s.OptionLayout = append(s.OptionLayout, "sok")
+			s.SACKPermitted = true
+		case kind == tcpOptSACK:
+			s.OptionLayout = append(s.OptionLayout, "sack")
+		case kind == tcpOptTimestamp && size == 10:
+			s.OptionLayout = append(s.OptionLayout, "ts")
+			s.Timestamp = true
+			s.TSval = binary.BigEndian.Uint32(val[0:4])
+			s.TSecr = binary.BigEndian.Uint32(val[4:8])
+			if s.TSval == 0 {
+				s.Quirks = append(s.Quirks, "ts1-")
+			}
+			if s.TSecr != 0 {
+				s.Quirks = append(s.Quirks, "ts2+")
+			}
+		default:
+			s.OptionLayout = append(s.OptionLayout, "?"+strconv.Itoa(int(kind)))
+		}
+		i += size
+	}
+	return nil
+}
+
+// Fingerprint returns p0f v3 style signature
+// ver:ittl:olen:mss:wsize,scale:olayout:quirks:pclass
+func (s *SYN) Fingerprint() string {
+	wsize := strconv.Itoa(int(s.Window))
+	if 0 < s.MSS && 0 < s.Window && (s.Window%s.MSS) == 0 {
+		wsize = "mss*" + strconv.Itoa(int(s.Window/s.MSS))
+	}
+	scale := "0"
+	if 0 <= s.WindowScale {
+		scale = strconv.Itoa(s.WindowScale)
+	}
+	mss := "*"
+	if 0 < s.MSS {
+		mss = strconv.Itoa(int(s.MSS))
+	}
+	pclass := "0"
+	if 0 < s.PayloadLen {
+		pclass = "+"
+	}
+	return strings.Join([]string{
+		strconv.Itoa(s.IPVersion),
+		strconv.Itoa(int(s.InitialTTL)),
+		strconv.Itoa(s.IPOptionsLen),
+		mss,
+		wsize + "," + scale,
+		strings.Join(s.OptionLayout, ","),
+		strings.Join(s.Quirks, ","),
+		pclass,
+	}, ":")
+}
+
+func guessInitialTTL(ttl uint8) uint8 {
+	switch {
+	case ttl <= 32:
+		return 32
+	case ttl <= 64:
+		return 64
+	case ttl <= 128:
+		return 128
+	}
+	return 255
+}
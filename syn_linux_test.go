@@ -0,0 +1,82 @@
+package tcpoption
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestSavedSYN(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen err: %+v", err)
+	}
+	defer listener.Close()
+
+	if err := SetSaveSYN(listener, true); err != nil {
+		t.Fatalf("save_syn set err: %+v", err)
+	}
+	if err := getFd(listener.(*net.TCPListener), func(fd int) error {
+		if v, err := getsockoptSaveSYN(fd); err != nil {
+			t.Errorf("save_syn get err: %+v", err)
+		} else {
+			if v != 1 {
+				t.Errorf("enable save_syn: %d", v)
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Errorf("must no error: %+v", err)
+	}
+
+	client, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("client open err: %+v", err)
+	}
+	defer client.Close()
+
+	server, err := listener.Accept()
+	if err != nil {
+		t.Fatalf("accept err: %+v", err)
+	}
+	defer server.Close()
+
+	b, err := SavedSYN(server)
+	if err != nil {
+		t.Fatalf("saved_syn err: %+v", err)
+	}
+	syn, err := ParseSYN(b)
+	if err != nil {
+		t.Fatalf("parse err: %+v", err)
+	}
+	t.Logf("fingerprint: %s", syn.Fingerprint())
+
+	local := client.LocalAddr().(*net.TCPAddr)
+	remote := listener.Addr().(*net.TCPAddr)
+	if syn.IPVersion != 4 {
+		t.Errorf("ipv4 syn: %d", syn.IPVersion)
+	}
+	if syn.SrcPort != uint16(local.Port) || syn.DstPort != uint16(remote.Port) {
+		t.Errorf("port %d -> %d", syn.SrcPort, syn.DstPort)
+	}
+	if syn.SrcIP.Equal(local.IP) != true {
+		t.Errorf("src ip: %s", syn.SrcIP)
+	}
+	if syn.TTL != 64 || syn.InitialTTL != 64 {
+		t.Errorf("linux ttl 64: %d/%d", syn.TTL, syn.InitialTTL)
+	}
+	if syn.MSS == 0 || syn.SACKPermitted != true || syn.Timestamp != true || syn.WindowScale < 0 {
+		t.Errorf("linux syn options: %+v", syn)
+	}
+	if strings.Join(syn.OptionLayout, ",") != "mss,sok,ts,nop,ws" {
+		t.Errorf("linux option layout: %v", syn.OptionLayout)
+	}
+
+	again, err := SavedSYN(server)
+	if err != nil {
+		t.Fatalf("saved_syn err: %+v", err)
+	}
+	if len(again) != 0 {
+		t.Errorf("saved syn must be released once read: %d bytes", len(again))
+	}
+}
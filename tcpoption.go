@@ -2,6 +2,7 @@ package tcpoption
 
 import (
 	"net"
+	"syscall"
 	"time"
 )
 
@@ -170,6 +171,49 @@ func SetDeferAcceptFd(fd int, enable bool) error {
 	return setsockoptDeferAccept(fd, IntBool(enable))
 }
 
+// SetDeferAcceptTimeout wakes up Accept only when data arrives within d.
+// the kernel converts d to SYN-ACK retransmissions, GetDeferAcceptTimeout reports the applied value.
+// darwin installs SO_ACCEPTFILTER "dataready" (no timeout) on listening sockets only, call it after Listen,
+// Config.DeferAccept applied before listen() is skipped there. GetDeferAcceptTimeout reports 1s while installed.
+func SetDeferAcceptTimeout(listener net.Listener, d time.Duration) error {
+	if l, ok := listener.(*net.TCPListener); ok {
+		return getFd(l, func(fd int) error {
+			return setsockoptDeferAccept(fd, CeilSecond(d))
+		})
+	}
+	return nil
+}
+
+func SetDeferAcceptTimeoutFd(fd int, d time.Duration) error {
+	return setsockoptDeferAccept(fd, CeilSecond(d))
+}
+
+func GetDeferAcceptTimeout(listener net.Listener) (time.Duration, error) {
+	if l, ok := listener.(*net.TCPListener); ok {
+		d := time.Duration(0)
+		if err := getFd(l, func(fd int) error {
+			v, err := GetDeferAcceptTimeoutFd(fd)
+			if err != nil {
+				return err
+			}
+			d = v
+			return nil
+		}); err != nil {
+			return 0, err
+		}
+		return d, nil
+	}
+	return 0, nil
+}
+
+func GetDeferAcceptTimeoutFd(fd int) (time.Duration, error) {
+	sec, err := getsockoptDeferAccept(fd)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(sec) * time.Second, nil
+}
+
 func SetReuseAddr(conn net.Conn, enable bool) error {
 	if c, ok := conn.(*net.TCPConn); ok {
 		return getFd(c, func(fd int) error {
@@ -196,6 +240,19 @@ func SetReusePortFd(fd int, enable bool) error {
 	return setsockoptReusePort(fd, IntBool(enable))
 }
 
+func SetCork(conn net.Conn, enable bool) error {
+	if c, ok := conn.(*net.TCPConn); ok {
+		return getFd(c, func(fd int) error {
+			return setsockoptCork(fd, IntBool(enable))
+		})
+	}
+	return nil
+}
+
+func SetCorkFd(fd int, enable bool) error {
+	return setsockoptCork(fd, IntBool(enable))
+}
+
 type Config struct {
 	NoLinger          bool
 	LingerTimeout     time.Duration
@@ -208,10 +265,34 @@ type Config struct {
 	KeepAliveProbes   int
 	FastOpen          int
 	FastOpenConnect   int
+	FastOpenNoCookie  bool
 	EnableQuickACK    bool
 	EnableDeferAccept bool
+	DeferAccept       time.Duration
 	EnableReuseAddr   bool
 	EnableReusePort   bool
+	Cork              bool
+	MaxSeg            int
+	WindowClamp       int
+	Retransmit        RetransmitPolicy
+	UserTimeout       time.Duration
+	MaxPacingRate     uint64
+	DSCP              DSCP
+	Mark              uint32
+	Priority          int
+	BindToDevice      string // applied before connect/bind only, see Dialer/ListenConfig
+	BindToIfindex     int    // applied before connect/bind only, see Dialer/ListenConfig
+	BindAddressNoPort bool   // applied before connect/bind only, see Dialer/ListenConfig
+	LocalPortRange    PortRange
+	Transparent       bool       // applied before connect/bind only, see Dialer/ListenConfig
+	FreeBind          bool       // applied before connect/bind only, see Dialer/ListenConfig
+	V6Only            V6OnlyMode // applied before bind only, see ListenConfig
+	MTUDiscover       PMTUDiscMode
+	TTL               int // TTL or hop limit by socket family
+	MinTTL            int // IP_MINTTL or IPV6_MINHOPCOUNT by socket family
+	GTSM              bool
+	MD5Keys           []MD5Key // applied before connect/bind only, see Dialer/ListenConfig
+	AOKeys            []AOKey  // applied before connect/bind only, see Dialer/ListenConfig
 }
 
 func Set(conn net.Conn, cfg Config) error {
@@ -273,10 +354,19 @@ func Set(conn net.Conn, cfg Config) error {
 				return err
 			}
 		}
+		if cfg.FastOpenNoCookie {
+			if err := setsockoptFastOpenNoCookie(fd, 1); err != nil {
+				return err
+			}
+		}
 		if err := setsockoptQuickACK(fd, IntBool(cfg.EnableQuickACK)); err != nil {
 			return err
 		}
-		if err := setsockoptDeferAccept(fd, IntBool(cfg.EnableDeferAccept)); err != nil {
+		deferAccept := IntBool(cfg.EnableDeferAccept)
+		if 0 < cfg.DeferAccept {
+			deferAccept = CeilSecond(cfg.DeferAccept)
+		}
+		if err := setsockoptDeferAccept(fd, deferAccept); err != nil {
 			return err
 		}
 		if err := setsockoptReuseAddr(fd, IntBool(cfg.EnableReuseAddr)); err != nil {
@@ -285,6 +375,67 @@ func Set(conn net.Conn, cfg Config) error {
 		if err := setsockoptReusePort(fd, IntBool(cfg.EnableReusePort)); err != nil {
 			return err
 		}
+		if err := setsockoptCork(fd, IntBool(cfg.Cork)); err != nil {
+			return err
+		}
+		if 0 < cfg.MaxSeg {
+			if err := setsockoptMaxSeg(fd, cfg.MaxSeg); err != nil {
+				return err
+			}
+		}
+		if 0 < cfg.WindowClamp {
+			if err := setsockoptWindowClamp(fd, cfg.WindowClamp); err != nil {
+				return err
+			}
+		}
+		if err := setRetransmitPolicy(fd, cfg.Retransmit); err != nil {
+			return err
+		}
+		if 0 < cfg.UserTimeout {
+			if err := setsockoptUserTimeout(fd, cfg.UserTimeout); err != nil {
+				return err
+			}
+		}
+		if 0 < cfg.MaxPacingRate {
+			if err := setsockoptMaxPacingRate(fd, cfg.MaxPacingRate); err != nil {
+				return err
+			}
+		}
+		if 0 < cfg.DSCP {
+			if err := SetDSCPFd(fd, cfg.DSCP); err != nil {
+				return err
+			}
+		}
+		if 0 < cfg.Mark {
+			if err := setsockoptMark(fd, cfg.Mark); err != nil {
+				return err
+			}
+		}
+		if 0 < cfg.Priority {
+			if err := setsockoptPriority(fd, cfg.Priority); err != nil {
+				return err
+			}
+		}
+		if cfg.MTUDiscover != PMTUDiscDefault {
+			if err := SetMTUDiscoverFd(fd, cfg.MTUDiscover); err != nil {
+				return err
+			}
+		}
+		if 0 < cfg.TTL {
+			if err := setTTLFd(fd, cfg.TTL); err != nil {
+				return err
+			}
+		}
+		if 0 < cfg.MinTTL {
+			if err := setMinTTLFd(fd, cfg.MinTTL); err != nil {
+				return err
+			}
+		}
+		if cfg.GTSM {
+			if err := GTSMFd(fd); err != nil {
+				return err
+			}
+		}
 		return nil
 	})
 }
@@ -293,6 +444,14 @@ func IntSecond(d time.Duration) int {
 	return int(d.Seconds())
 }
 
+func CeilSecond(d time.Duration) int {
+	sec := IntSecond(d)
+	if time.Duration(sec)*time.Second < d {
+		return sec + 1
+	}
+	return sec
+}
+
 func IntBool(b bool) int {
 	if b {
 		return 1
@@ -300,7 +459,7 @@ func IntBool(b bool) int {
 	return 0
 }
 
-func getFd(conn *net.TCPConn, cb func(int) error) error {
+func getFd(conn syscall.Conn, cb func(int) error) error {
 	raw, err := conn.SyscallConn()
 	if err != nil {
 		return err
@@ -313,3 +472,29 @@ func getFd(conn *net.TCPConn, cb func(int) error) error {
 	}
 	return fdErr
 }
+
+func resolveSockaddr(address string) (syscall.Sockaddr, error) {
+	addr, err := net.ResolveTCPAddr("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	return tcpSockaddr(addr)
+}
+
+func tcpSockaddr(addr *net.TCPAddr) (syscall.Sockaddr, error) {
+	if ip4 := addr.IP.To4(); ip4 != nil {
+		sa := &syscall.SockaddrInet4{Port: addr.Port}
+		copy(sa.Addr[:], ip4)
+		return sa, nil
+	}
+	sa := &syscall.SockaddrInet6{Port: addr.Port}
+	copy(sa.Addr[:], addr.IP.To16())
+	if addr.Zone != "" {
+		ifi, err := net.InterfaceByName(addr.Zone)
+		if err != nil {
+			return nil, err
+		}
+		sa.ZoneId = uint32(ifi.Index)
+	}
+	return sa, nil
+}
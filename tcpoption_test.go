@@ -1,10 +1,14 @@
 package tcpoption
 
 import (
+	"errors"
 	"fmt"
 	"net"
+	"os"
 	"sync"
+	"syscall"
 	"testing"
+	"time"
 )
 
 func TestGetFd(t *testing.T) {
@@ -58,3 +62,255 @@ func TestGetFd(t *testing.T) {
 	listener.Close()
 	wg.Wait()
 }
+
+func TestCeilSecond(t *testing.T) {
+	tt := []struct {
+		d      time.Duration
+		expect int
+	}{
+		{0, 0},
+		{1 * time.Millisecond, 1},
+		{1 * time.Second, 1},
+		{1500 * time.Millisecond, 2},
+		{30 * time.Second, 30},
+	}
+	for _, tc := range tt {
+		if v := CeilSecond(tc.d); v != tc.expect {
+			t.Errorf("%s expect %d, actual %d", tc.d, tc.expect, v)
+		}
+	}
+}
+
+func TestHMACKeySource(t *testing.T) {
+	a := HMACKeySource([]byte("secret"))
+	b := HMACKeySource([]byte("secret"))
+	c := HMACKeySource([]byte("other"))
+
+	k1, _ := a(100)
+	k2, _ := b(100)
+	k3, _ := c(100)
+	k4, _ := a(101)
+	if k1 != k2 {
+		t.Errorf("same secret and epoch must derive same key")
+	}
+	if k1 == k3 {
+		t.Errorf("different secret must derive different key")
+	}
+	if k1 == k4 {
+		t.Errorf("different epoch must derive different key")
+	}
+}
+
+func TestParseSYN(t *testing.T) {
+	// 10.0.0.1:40000 -> 10.0.0.2:80, ttl 128, df, win 64240, mss 1460, nop, ws 8, nop, nop, sok
+	b := []byte{
+		0x45, 0x00, 0x00, 0x34, 0x12, 0x34, 0x40, 0x00, 0x80, 0x06, 0x00, 0x00,
+		0x0a, 0x00, 0x00, 0x01, 0x0a, 0x00, 0x00, 0x02,
+		0x9c, 0x40, 0x00, 0x50, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00,
+		0x80, 0x02, 0xfa, 0xf0, 0x00, 0x00, 0x00, 0x00,
+		0x02, 0x04, 0x05, 0xb4, 0x01, 0x03, 0x03, 0x08, 0x01, 0x01, 0x04, 0x02,
+	}
+	syn, err := ParseSYN(b)
+	if err != nil {
+		t.Fatalf("parse err: %+v", err)
+	}
+	if syn.SrcPort != 40000 || syn.DstPort != 80 {
+		t.Errorf("port %d -> %d", syn.SrcPort, syn.DstPort)
+	}
+	if syn.MSS != 1460 || syn.WindowScale != 8 || syn.SACKPermitted != true || syn.Timestamp {
+		t.Errorf("options: %+v", syn)
+	}
+	expect := "4:128:0:1460:mss*44,8:mss,nop,ws,nop,nop,sok:df,id+:0"
+	if v := syn.Fingerprint(); v != expect {
+		t.Errorf("expect %s, actual %s", expect, v)
+	}
+
+	if _, err := ParseSYN(b[:30]); err == nil {
+		t.Errorf("truncated syn must be error")
+	}
+}
+
+func TestValidateMaxSeg(t *testing.T) {
+	tt := []struct {
+		mss   int
+		mtu   int
+		ipv6  bool
+		valid bool
+	}{
+		{1460, 1500, false, true},
+		{1461, 1500, false, false},
+		{1440, 1500, true, true},
+		{1460, 1500, true, false},
+		{1220, 1280, true, true},
+		{8960, 9000, false, true},
+		{64, 1500, false, false},
+	}
+	for _, tc := range tt {
+		err := validateMaxSeg(tc.mss, tc.mtu, tc.ipv6)
+		if tc.valid && err != nil {
+			t.Errorf("mss %d mtu %d ipv6 %v must be valid: %+v", tc.mss, tc.mtu, tc.ipv6, err)
+		}
+		if tc.valid != true && err == nil {
+			t.Errorf("mss %d mtu %d ipv6 %v must be invalid", tc.mss, tc.mtu, tc.ipv6)
+		}
+	}
+}
+
+func TestRetransmitPolicyTimeout(t *testing.T) {
+	tt := []struct {
+		policy  RetransmitPolicy
+		connect time.Duration
+	}{
+		{RetransmitPolicy{}, 127 * time.Second},
+		{RetransmitPolicy{SynRetries: 1}, 3 * time.Second},
+		{RetransmitPolicy{SynRetries: 3}, 15 * time.Second},
+		{RetransmitPolicy{SynRetries: 8}, 127*time.Second + 2*120*time.Second},
+	}
+	for _, tc := range tt {
+		if v := tc.policy.ConnectTimeout(); v != tc.connect {
+			t.Errorf("syn_retries %d expect %s, actual %s", tc.policy.SynRetries, tc.connect, v)
+		}
+	}
+	if v := DeadPeerTimeout(); v != 924600*time.Millisecond {
+		t.Errorf("tcp_retries2=15 must be 924.6s, actual %s", v)
+	}
+}
+
+func TestDeadPeerTimeoutParams(t *testing.T) {
+	tt := []struct {
+		total  time.Duration
+		opts   DeadPeerOptions
+		expect DeadPeerParams
+	}{
+		{30 * time.Second, DeadPeerOptions{}, DeadPeerParams{15 * time.Second, 5 * time.Second, 3, 30 * time.Second}},
+		{2 * time.Second, DeadPeerOptions{}, DeadPeerParams{1 * time.Second, 1 * time.Second, 1, 2 * time.Second}},
+		{60 * time.Second, DeadPeerOptions{Probes: 5, Interval: 2 * time.Second}, DeadPeerParams{50 * time.Second, 2 * time.Second, 5, 60 * time.Second}},
+		{100000 * time.Second, DeadPeerOptions{}, DeadPeerParams{32767 * time.Second, 22411 * time.Second, 3, 100000 * time.Second}},
+	}
+	for _, tc := range tt {
+		p, err := DeadPeerTimeoutParams(tc.total, tc.opts)
+		if err != nil {
+			t.Errorf("%s must no error: %+v", tc.total, err)
+			continue
+		}
+		if p != tc.expect {
+			t.Errorf("%s expect %+v, actual %+v", tc.total, tc.expect, p)
+		}
+		if p.KeepAliveIdle+(time.Duration(p.KeepAliveProbes)*p.KeepAliveInterval) != p.UserTimeout {
+			t.Errorf("idle + probes * interval must equal user timeout: %+v", p)
+		}
+		if tc.total < p.UserTimeout {
+			t.Errorf("%s exceeds budget: %+v", tc.total, p)
+		}
+	}
+
+	invalid := []struct {
+		total time.Duration
+		opts  DeadPeerOptions
+	}{
+		{1500 * time.Millisecond, DeadPeerOptions{}},
+		{30 * time.Second, DeadPeerOptions{Probes: 128}},
+		{30 * time.Second, DeadPeerOptions{Probes: 10, Interval: 3 * time.Second}},
+		{30 * time.Second, DeadPeerOptions{Interval: 1500 * time.Millisecond}},
+		{200000 * time.Second, DeadPeerOptions{}},
+	}
+	for _, tc := range invalid {
+		if p, err := DeadPeerTimeoutParams(tc.total, tc.opts); err == nil {
+			t.Errorf("%s %+v must be refused: %+v", tc.total, tc.opts, p)
+		}
+	}
+}
+
+func TestPacingScheduleRateAt(t *testing.T) {
+	s := PacingSchedule{
+		Windows: []PacingWindow{
+			{From: 9 * time.Hour, To: 18 * time.Hour, Rate: 10},
+			{From: 22 * time.Hour, To: 2 * time.Hour, Rate: 20},
+		},
+		Default: 30,
+	}
+	tt := []struct {
+		hour   int
+		expect uint64
+	}{
+		{8, 30},
+		{9, 10},
+		{17, 10},
+		{18, 30},
+		{23, 20},
+		{1, 20},
+		{2, 30},
+	}
+	for _, tc := range tt {
+		at := time.Date(2022, 1, 1, tc.hour, 30, 0, 0, time.Local)
+		if v := s.RateAt(at); v != tc.expect {
+			t.Errorf("%02d:30 expect %d, actual %d", tc.hour, tc.expect, v)
+		}
+	}
+}
+
+func TestWithinRatio(t *testing.T) {
+	if withinRatio(100, 120, 0.25) != true {
+		t.Errorf("20%% change is within 25%%")
+	}
+	if withinRatio(100, 70, 0.25) {
+		t.Errorf("30%% change is not within 25%%")
+	}
+}
+
+func TestDSCPTOS(t *testing.T) {
+	tests := []struct {
+		dscp    DSCP
+		current int
+		expect  int
+		name    string
+	}{
+		{DSCP_CS0, 0x00, 0x00, "CS0"},
+		{DSCP_EF, 0x00, 0xb8, "EF"},
+		{DSCP_EF, 0x03, 0xbb, "EF"},
+		{DSCP_AF11, 0xfe, 0x2a, "AF11"},
+		{DSCP_CS7, 0x01, 0xe1, "CS7"},
+		{DSCP(5), 0x00, 0x14, "DSCP(5)"},
+	}
+	for _, tc := range tests {
+		if v := tc.dscp.TOS(tc.current); v != tc.expect {
+			t.Errorf("%s TOS(%#x) expect:%#x actual:%#x", tc.dscp, tc.current, tc.expect, v)
+		}
+		if tc.dscp.String() != tc.name {
+			t.Errorf("expect name %s actual %s", tc.name, tc.dscp)
+		}
+	}
+}
+
+func TestPrivilegeError(t *testing.T) {
+	err := privilegeError("SO_MARK", "CAP_NET_ADMIN", os.NewSyscallError("setsockopt", syscall.EPERM))
+	if IsPrivilegeError(err) != true {
+		t.Errorf("EPERM must be privilege error: %+v", err)
+	}
+	if errors.Is(err, syscall.EPERM) != true {
+		t.Errorf("must unwrap to EPERM: %+v", err)
+	}
+	if err.Error() != "SO_MARK requires CAP_NET_ADMIN: setsockopt: operation not permitted" {
+		t.Errorf("unexpected message: %s", err)
+	}
+	if err := privilegeError("SO_MARK", "CAP_NET_ADMIN", syscall.EINVAL); IsPrivilegeError(err) {
+		t.Errorf("EINVAL is not privilege error: %+v", err)
+	}
+}
+
+func TestPMTUDiscModeString(t *testing.T) {
+	tests := []struct {
+		mode   PMTUDiscMode
+		expect string
+	}{
+		{PMTUDiscDefault, "default"},
+		{PMTUDiscDo, "do"},
+		{PMTUDiscOmit, "omit"},
+		{PMTUDiscMode(9), "PMTUDiscMode(9)"},
+	}
+	for _, tc := range tests {
+		if tc.mode.String() != tc.expect {
+			t.Errorf("expect %s actual %s", tc.expect, tc.mode)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package tcpoption
+
+import (
+	"fmt"
+	"net"
+)
+
+// SetTransparent lets the socket bind to and accept for non-local addresses (TPROXY),
+// requires CAP_NET_ADMIN (or CAP_NET_RAW). bind happens before this returns on established conns,
+// so use Dialer/ListenConfig with Config.Transparent or SetTransparentFd in Control.
+func SetTransparent(conn net.Conn, enable bool) error {
+	if c, ok := conn.(*net.TCPConn); ok {
+		return getFd(c, func(fd int) error {
+			return SetTransparentFd(fd, enable)
+		})
+	}
+	return nil
+}
+
+func SetTransparentFd(fd int, enable bool) error {
+	ipv6, err := isIPv6Socket(fd)
+	if err != nil {
+		return err
+	}
+	if ipv6 {
+		return setsockoptIPv6Transparent(fd, IntBool(enable))
+	}
+	return setsockoptTransparent(fd, IntBool(enable))
+}
+
+func GetTransparent(conn net.Conn) (bool, error) {
+	if c, ok := conn.(*net.TCPConn); ok {
+		enable := false
+		if err := getFd(c, func(fd int) error {
+			v, err := GetTransparentFd(fd)
+			if err != nil {
+				return err
+			}
+			enable = v
+			return nil
+		}); err != nil {
+			return false, err
+		}
+		return enable, nil
+	}
+	return false, nil
+}
+
+func GetTransparentFd(fd int) (bool, error) {
+	ipv6, err := isIPv6Socket(fd)
+	if err != nil {
+		return false, err
+	}
+	v := 0
+	if ipv6 {
+		v, err = getsockoptIPv6Transparent(fd)
+	} else {
+		v, err = getsockoptTransparent(fd)
+	}
+	if err != nil {
+		return false, err
+	}
+	return v != 0, nil
+}
+
+// SetFreeBind allows binding to an address not (yet) configured on the host, no privilege required.
+func SetFreeBind(conn net.Conn, enable bool) error {
+	if c, ok := conn.(*net.TCPConn); ok {
+		return getFd(c, func(fd int) error {
+			return SetFreeBindFd(fd, enable)
+		})
+	}
+	return nil
+}
+
+func SetFreeBindFd(fd int, enable bool) error {
+	ipv6, err := isIPv6Socket(fd)
+	if err != nil {
+		return err
+	}
+	if ipv6 {
+		return setsockoptIPv6FreeBind(fd, IntBool(enable))
+	}
+	return setsockoptFreeBind(fd, IntBool(enable))
+}
+
+func GetFreeBind(conn net.Conn) (bool, error) {
+	if c, ok := conn.(*net.TCPConn); ok {
+		enable := false
+		if err := getFd(c, func(fd int) error {
+			v, err := GetFreeBindFd(fd)
+			if err != nil {
+				return err
+			}
+			enable = v
+			return nil
+		}); err != nil {
+			return false, err
+		}
+		return enable, nil
+	}
+	return false, nil
+}
+
+func GetFreeBindFd(fd int) (bool, error) {
+	ipv6, err := isIPv6Socket(fd)
+	if err != nil {
+		return false, err
+	}
+	v := 0
+	if ipv6 {
+		v, err = getsockoptIPv6FreeBind(fd)
+	} else {
+		v, err = getsockoptFreeBind(fd)
+	}
+	if err != nil {
+		return false, err
+	}
+	return v != 0, nil
+}
+
+// OriginalDestination returns the address the client connected to.
+// conns accepted by a transparent listener keep the original destination as local address.
+func OriginalDestination(conn net.Conn) (*net.TCPAddr, error) {
+	addr, ok := conn.LocalAddr().(*net.TCPAddr)
+	if ok != true {
+		return nil, fmt.Errorf("not tcp address: %s", conn.LocalAddr())
+	}
+	if ip4 := addr.IP.To4(); ip4 != nil {
+		return &net.TCPAddr{IP: ip4, Port: addr.Port}, nil // unmap v4-mapped on dual-stack listener
+	}
+	return addr, nil
+}
@@ -0,0 +1,144 @@
+package tcpoption
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+func TestSetFreeBind(t *testing.T) {
+	// TEST-NET-1, not assigned on this host
+	t.Run("without", func(tt *testing.T) {
+		listener, err := Listen(context.TODO(), "tcp4", "192.0.2.10:0", Config{})
+		if err == nil {
+			listener.Close()
+			tt.Skipf("192.0.2.10 is local address on this host")
+		}
+		if errors.Is(err, syscall.EADDRNOTAVAIL) != true {
+			tt.Errorf("expect EADDRNOTAVAIL, actual:%+v", err)
+		}
+	})
+	t.Run("with", func(tt *testing.T) {
+		listener, err := Listen(context.TODO(), "tcp4", "192.0.2.10:0", Config{FreeBind: true})
+		if err != nil {
+			tt.Fatalf("listen err: %+v", err)
+		}
+		defer listener.Close()
+
+		l := listener.(*net.TCPListener)
+		enable := false
+		if err := getFd(l, func(fd int) error {
+			v, err := GetFreeBindFd(fd)
+			enable = v
+			return err
+		}); err != nil {
+			tt.Fatalf("freebind get err: %+v", err)
+		}
+		if enable != true {
+			tt.Errorf("IP_FREEBIND must be set")
+		}
+	})
+	t.Run("ipv6", func(tt *testing.T) {
+		listener, err := Listen(context.TODO(), "tcp6", "[2001:db8::10]:0", Config{FreeBind: true})
+		if err != nil {
+			tt.Skipf("ipv6 listen err: %+v", err)
+		}
+		defer listener.Close()
+
+		l := listener.(*net.TCPListener)
+		enable := false
+		if err := getFd(l, func(fd int) error {
+			v, err := GetFreeBindFd(fd)
+			enable = v
+			return err
+		}); err != nil {
+			tt.Fatalf("freebind get err: %+v", err)
+		}
+		if enable != true {
+			tt.Errorf("IPV6_FREEBIND must be set")
+		}
+	})
+}
+
+func TestSetTransparent(t *testing.T) {
+	t.Run("listener", func(tt *testing.T) {
+		listener, err := Listen(context.TODO(), "tcp4", "192.0.2.11:0", Config{Transparent: true})
+		if err != nil {
+			if IsPrivilegeError(err) {
+				tt.Skipf("CAP_NET_ADMIN required: %+v", err)
+			}
+			tt.Fatalf("listen err: %+v", err)
+		}
+		defer listener.Close()
+
+		l := listener.(*net.TCPListener)
+		enable := false
+		if err := getFd(l, func(fd int) error {
+			v, err := GetTransparentFd(fd)
+			enable = v
+			return err
+		}); err != nil {
+			tt.Fatalf("transparent get err: %+v", err)
+		}
+		if enable != true {
+			tt.Errorf("IP_TRANSPARENT must be set")
+		}
+	})
+	t.Run("dialer", func(tt *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			tt.Fatalf("listen err: %+v", err)
+		}
+		defer listener.Close()
+		accepted := acceptLoop(tt, listener)
+
+		d := Dialer(Config{Transparent: true})
+		d.LocalAddr = &net.TCPAddr{IP: net.ParseIP("127.0.0.9")}
+		client, err := d.DialContext(context.TODO(), "tcp", listener.Addr().String())
+		if err != nil {
+			if IsPrivilegeError(err) {
+				tt.Skipf("CAP_NET_ADMIN required: %+v", err)
+			}
+			tt.Fatalf("dial err: %+v", err)
+		}
+		defer client.Close()
+		server := <-accepted
+		defer server.Close()
+
+		if enable, err := GetTransparent(client); err != nil || enable != true {
+			tt.Errorf("IP_TRANSPARENT must be set: %v %+v", enable, err)
+		}
+		if ip := server.RemoteAddr().(*net.TCPAddr).IP.String(); ip != "127.0.0.9" {
+			tt.Errorf("source 127.0.0.9, actual:%s", ip)
+		}
+	})
+}
+
+func TestOriginalDestination(t *testing.T) {
+	listener, err := net.Listen("tcp", "[::]:0")
+	if err != nil {
+		t.Skipf("dual-stack listen err: %+v", err)
+	}
+	defer listener.Close()
+	accepted := acceptLoop(t, listener)
+
+	port := listener.Addr().(*net.TCPAddr).Port
+	client, err := net.Dial("tcp4", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	if err != nil {
+		t.Fatalf("dial err: %+v", err)
+	}
+	defer client.Close()
+	server := <-accepted
+	defer server.Close()
+
+	dst, err := OriginalDestination(server)
+	if err != nil {
+		t.Fatalf("original destination err: %+v", err)
+	}
+	if dst.String() != client.RemoteAddr().String() {
+		t.Errorf("expect %s, actual:%s", client.RemoteAddr(), dst)
+	}
+}
@@ -0,0 +1,296 @@
+package tcpoption
+
+import (
+	"errors"
+	"log"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	DefaultTunerHeadroom float64       = 2.0
+	DefaultTunerInterval time.Duration = time.Second
+	minNotSentLowat      int           = 16 * 1024
+	tunerChangeRatio     float64       = 0.25
+	unlockSendBuffer     int           = 1 // SOCK_SNDBUF_LOCK
+	unlockRecvBuffer     int           = 2 // SOCK_RCVBUF_LOCK
+)
+
+type BufferTunerConfig struct {
+	MinBuffer    int
+	MaxBuffer    int
+	Headroom     float64 // multiplier of BDP, DefaultTunerHeadroom if 0
+	Interval     time.Duration
+	NotSentLowat bool // also adjust TCP_NOTSENT_LOWAT to BDP
+	Logger       *log.Logger
+}
+
+type BufferDecision struct {
+	MinRTT       time.Duration
+	DeliveryRate uint64 // bytes per second
+	BDP          int
+	Target       int
+	Autotune     bool // both buffers are left to kernel autotuning
+	SendAutotune bool
+	RecvAutotune bool
+	SendBuffer   int // value read back from kernel
+	RecvBuffer   int
+	Limited      bool // a buffer cannot reach Target (net.core.*mem_max or tcp_*mem max)
+	NotSentLowat int
+	Changed      bool
+}
+
+// BufferTuner sizes socket buffers from the measured bandwidth-delay product.
+// pinning SO_SNDBUF/SO_RCVBUF disables kernel autotuning, so each buffer is pinned only
+// when pinning reaches further than autotuning (tcp_rmem/tcp_wmem max), plain setsockopt
+// is capped by net.core.rmem_max/wmem_max unless SO_RCVBUFFORCE/SO_SNDBUFFORCE is permitted.
+// otherwise the lock is released with SO_BUF_LOCK.
+type BufferTuner struct {
+	conn net.Conn
+	cfg  BufferTunerConfig
+
+	mutex      sync.Mutex
+	last       BufferDecision
+	sendPinned bool
+	recvPinned bool
+	done       chan struct{}
+	wg         sync.WaitGroup
+}
+
+func NewBufferTuner(conn net.Conn, cfg BufferTunerConfig) *BufferTuner {
+	if cfg.Headroom <= 0 {
+		cfg.Headroom = DefaultTunerHeadroom
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultTunerInterval
+	}
+	return &BufferTuner{
+		conn: conn,
+		cfg:  cfg,
+	}
+}
+
+func (t *BufferTuner) Last() BufferDecision {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return t.last
+}
+
+// Tune takes one TCP_INFO sample and applies the decision
+func (t *BufferTuner) Tune() (BufferDecision, error) {
+	c, ok := t.conn.(*net.TCPConn)
+	if ok != true {
+		return BufferDecision{}, nil
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	d := BufferDecision{}
+	if err := getFd(c, func(fd int) error {
+		v, err := t.decide(fd)
+		if err != nil {
+			return err
+		}
+		d = v
+		return nil
+	}); err != nil {
+		return BufferDecision{}, err
+	}
+	t.last = d
+	if d.Changed && t.cfg.Logger != nil {
+		t.cfg.Logger.Printf(
+			"tcpoption: min_rtt=%s delivery_rate=%dB/s bdp=%d target=%d autotune=%v/%v sndbuf=%d rcvbuf=%d limited=%v notsent_lowat=%d",
+			d.MinRTT, d.DeliveryRate, d.BDP, d.Target, d.SendAutotune, d.RecvAutotune, d.SendBuffer, d.RecvBuffer, d.Limited, d.NotSentLowat,
+		)
+	}
+	return d, nil
+}
+
+func (t *BufferTuner) decide(fd int) (BufferDecision, error) {
+	minRTT, rate, err := getsockoptBDPSample(fd)
+	if err != nil {
+		return BufferDecision{}, err
+	}
+	d := BufferDecision{
+		MinRTT:       minRTT,
+		DeliveryRate: rate,
+		SendAutotune: t.sendPinned != true,
+		RecvAutotune: t.recvPinned != true,
+	}
+	d.Autotune = d.SendAutotune && d.RecvAutotune
+	if minRTT <= 0 || rate == 0 {
+		return d, nil // not enough samples yet
+	}
+
+	d.BDP = int(float64(rate) * minRTT.Seconds())
+	d.Target = t.clamp(int(float64(d.BDP) * t.cfg.Headroom))
+	if t.last.Target != 0 && withinRatio(t.last.Target, d.Target, tunerChangeRatio) {
+		last := t.last
+		last.MinRTT, last.DeliveryRate, last.BDP, last.Changed = minRTT, rate, d.BDP, false
+		return last, nil
+	}
+	d.Changed = true
+
+	rmax, wmax := autotuneMax()
+	rpin, wpin := pinBufferMax()
+	sendPin, err := t.pinBuffer(fd, d.Target, wmax, wpin, setsockoptSendBuffer, setsockoptSendBufferForce)
+	if err != nil {
+		return BufferDecision{}, err
+	}
+	recvPin, err := t.pinBuffer(fd, d.Target, rmax, rpin, setsockoptRecvBuffer, setsockoptRecvBufferForce)
+	if err != nil {
+		return BufferDecision{}, err
+	}
+	if (t.sendPinned && sendPin != true) || (t.recvPinned && recvPin != true) {
+		// SO_BUF_LOCK keeps the lock of the buffer still pinned
+		lock := 0
+		if sendPin {
+			lock |= unlockSendBuffer
+		}
+		if recvPin {
+			lock |= unlockRecvBuffer
+		}
+		if err := setsockoptBufLock(fd, lock); err != nil {
+			if errors.Is(err, syscall.ENOPROTOOPT) != true {
+				return BufferDecision{}, err
+			}
+			// kernel < 5.14 cannot unlock, keep pinning to target
+			if t.sendPinned && sendPin != true {
+				if err := setsockoptSendBuffer(fd, bufferRequest(d.Target)); err != nil {
+					return BufferDecision{}, err
+				}
+				sendPin = true
+			}
+			if t.recvPinned && recvPin != true {
+				if err := setsockoptRecvBuffer(fd, bufferRequest(d.Target)); err != nil {
+					return BufferDecision{}, err
+				}
+				recvPin = true
+			}
+		}
+	}
+	t.sendPinned, t.recvPinned = sendPin, recvPin
+	d.SendAutotune, d.RecvAutotune = sendPin != true, recvPin != true
+	d.Autotune = d.SendAutotune && d.RecvAutotune
+
+	if t.cfg.NotSentLowat {
+		lowat := d.BDP
+		if 0 < t.cfg.MaxBuffer && t.cfg.MaxBuffer < lowat {
+			lowat = t.cfg.MaxBuffer
+		}
+		if lowat < minNotSentLowat {
+			lowat = minNotSentLowat
+		}
+		if err := setsockoptNotSentLowat(fd, lowat); err != nil {
+			return BufferDecision{}, err
+		}
+		d.NotSentLowat = lowat
+	}
+
+	if d.SendBuffer, err = getsockoptSendBuffer(fd); err != nil {
+		return BufferDecision{}, err
+	}
+	if d.RecvBuffer, err = getsockoptRecvBuffer(fd); err != nil {
+		return BufferDecision{}, err
+	}
+	d.Limited = bufferLimited(sendPin, d.SendBuffer, d.Target, wmax) || bufferLimited(recvPin, d.RecvBuffer, d.Target, rmax)
+	return d, nil
+}
+
+// pinBuffer pins one buffer when that reaches further than autotuning (autoMax),
+// pinMax is the read back ceiling of plain setsockopt, 0 when unknown
+func (t *BufferTuner) pinBuffer(fd, target, autoMax, pinMax int, set, force func(int, int) error) (bool, error) {
+	if target <= autoMax {
+		return false, nil
+	}
+	if 0 < pinMax && pinMax < target {
+		err := force(fd, bufferRequest(target))
+		if err == nil {
+			return true, nil
+		}
+		if IsPrivilegeError(err) != true && errors.Is(err, syscall.ENOPROTOOPT) != true {
+			return false, err
+		}
+		if pinMax <= autoMax {
+			return false, nil // capped pinning would lower the autotuning ceiling
+		}
+	}
+	if err := set(fd, bufferRequest(target)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func bufferLimited(pinned bool, actual, target, autoMax int) bool {
+	if pinned {
+		return actual < target
+	}
+	return 0 < autoMax && autoMax < target
+}
+
+func (t *BufferTuner) clamp(v int) int {
+	if 0 < t.cfg.MinBuffer && v < t.cfg.MinBuffer {
+		return t.cfg.MinBuffer
+	}
+	if 0 < t.cfg.MaxBuffer && t.cfg.MaxBuffer < v {
+		return t.cfg.MaxBuffer
+	}
+	return v
+}
+
+func (t *BufferTuner) Start() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.done != nil {
+		return
+	}
+	done := make(chan struct{})
+	t.done = done
+	t.wg.Add(1)
+	go t.run(done)
+}
+
+func (t *BufferTuner) Stop() {
+	t.mutex.Lock()
+	if t.done != nil {
+		close(t.done)
+		t.done = nil
+	}
+	t.mutex.Unlock()
+
+	t.wg.Wait()
+}
+
+func (t *BufferTuner) run(done chan struct{}) {
+	defer t.wg.Done()
+
+	tick := time.NewTicker(t.cfg.Interval)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-tick.C:
+			if _, err := t.Tune(); err != nil {
+				if t.cfg.Logger != nil {
+					t.cfg.Logger.Printf("tcpoption: buffer tune err: %+v", err)
+				}
+				return
+			}
+		}
+	}
+}
+
+func withinRatio(prev, next int, ratio float64) bool {
+	diff := float64(next - prev)
+	if diff < 0 {
+		diff = -diff
+	}
+	return diff <= float64(prev)*ratio
+}
@@ -0,0 +1,198 @@
+package tcpoption
+
+import (
+	"bytes"
+	"log"
+	"net"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestBufferTuner(t *testing.T) {
+	client, server := setupConnPair(t)
+	defer client.Close()
+	defer server.Close()
+
+	measureTransfer(t, client, server, 8*1024*1024)
+
+	out := bytes.NewBuffer(nil)
+	tuner := NewBufferTuner(client, BufferTunerConfig{
+		MinBuffer:    16 * 1024 * 1024,
+		NotSentLowat: true,
+		Logger:       log.New(out, "", 0),
+	})
+
+	d, err := tuner.Tune()
+	if err != nil {
+		t.Fatalf("tune err: %+v", err)
+	}
+	t.Logf("pinned: %+v", d)
+	if d.MinRTT <= 0 || d.DeliveryRate == 0 || d.BDP <= 0 {
+		t.Fatalf("bdp must be sampled: %+v", d)
+	}
+	if d.Changed != true || d.Autotune {
+		t.Errorf("target over autotune max must pin buffers: %+v", d)
+	}
+	if d.NotSentLowat < minNotSentLowat {
+		t.Errorf("notsent_lowat must be set: %+v", d)
+	}
+	if strings.Contains(out.String(), "bdp=") != true {
+		t.Errorf("decision must be logged: %q", out.String())
+	}
+	if err := getFd(client.(*net.TCPConn), func(fd int) error {
+		if v, err := getsockoptBufLock(fd); err != nil {
+			t.Errorf("buf_lock get err: %+v", err)
+		} else {
+			if v == 0 {
+				t.Errorf("buffers must be locked")
+			}
+		}
+		if v, err := getsockoptNotSentLowat(fd); err != nil {
+			t.Errorf("notsent_lowat get err: %+v", err)
+		} else {
+			if v != d.NotSentLowat {
+				t.Errorf("notsent_lowat %d, actual:%d", d.NotSentLowat, v)
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Errorf("must no error: %+v", err)
+	}
+
+	tuner.cfg.MinBuffer = 0
+	tuner.cfg.MaxBuffer = 64 * 1024
+	d, err = tuner.Tune()
+	if err != nil {
+		t.Fatalf("tune err: %+v", err)
+	}
+	t.Logf("autotune: %+v", d)
+	if d.Changed != true || d.Autotune != true {
+		t.Errorf("target within autotune max must release buffers: %+v", d)
+	}
+	if err := getFd(client.(*net.TCPConn), func(fd int) error {
+		if v, err := getsockoptBufLock(fd); err != nil {
+			t.Errorf("buf_lock get err: %+v", err)
+		} else {
+			if v != 0 {
+				t.Errorf("buffers must be unlocked: %d", v)
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Errorf("must no error: %+v", err)
+	}
+
+	again, err := tuner.Tune()
+	if err != nil {
+		t.Fatalf("tune err: %+v", err)
+	}
+	if again.Changed {
+		t.Errorf("same target must not be re-applied: %+v", again)
+	}
+}
+
+func TestBufferTunerReadBack(t *testing.T) {
+	client, server := setupConnPair(t)
+	defer client.Close()
+	defer server.Close()
+
+	measureTransfer(t, client, server, 8*1024*1024)
+
+	tuner := NewBufferTuner(client, BufferTunerConfig{
+		MinBuffer: 16 * 1024 * 1024,
+	})
+	d, err := tuner.Tune()
+	if err != nil {
+		t.Fatalf("tune err: %+v", err)
+	}
+	rmax, wmax := autotuneMax()
+	rpin, wpin := pinBufferMax()
+	t.Logf("decision: %+v tcp_rmem=%d tcp_wmem=%d rmem_max*2=%d wmem_max*2=%d", d, rmax, wmax, rpin, wpin)
+
+	check := func(name string, autotune bool, actual, autoMax, pinMax int) {
+		if autotune {
+			// pinning must not lower the ceiling autotuning can reach
+			if d.Target <= autoMax {
+				return
+			}
+			if pinMax <= autoMax && actual < d.Target && d.Limited {
+				return
+			}
+			t.Errorf("%s: target %d over autotune max %d must pin: %+v", name, d.Target, autoMax, d)
+			return
+		}
+		if d.Target <= autoMax {
+			t.Errorf("%s: target %d within autotune max %d must not pin: %+v", name, d.Target, autoMax, d)
+		}
+		if actual == d.Target {
+			return
+		}
+		// SO_*BUFFORCE not permitted, plain setsockopt is capped at net.core.*mem_max
+		if actual != pinMax || d.Limited != true {
+			t.Errorf("%s: read back %d, target %d, must be %d and limited: %+v", name, actual, d.Target, pinMax, d)
+		}
+	}
+	check("sndbuf", d.SendAutotune, d.SendBuffer, wmax, wpin)
+	check("rcvbuf", d.RecvAutotune, d.RecvBuffer, rmax, rpin)
+
+	if err := getFd(client.(*net.TCPConn), func(fd int) error {
+		if v, err := getsockoptSendBuffer(fd); err != nil {
+			t.Errorf("sndbuf get err: %+v", err)
+		} else if v != d.SendBuffer {
+			t.Errorf("sndbuf %d, actual:%d", d.SendBuffer, v)
+		}
+		if v, err := getsockoptRecvBuffer(fd); err != nil {
+			t.Errorf("rcvbuf get err: %+v", err)
+		} else if v != d.RecvBuffer {
+			t.Errorf("rcvbuf %d, actual:%d", d.RecvBuffer, v)
+		}
+		return nil
+	}); err != nil {
+		t.Errorf("must no error: %+v", err)
+	}
+}
+
+func TestBufferTunerUnprivileged(t *testing.T) {
+	client, server := setupConnPair(t)
+	defer client.Close()
+	defer server.Close()
+
+	_, wmax := autotuneMax()
+	_, wpin := pinBufferMax()
+	target := 2 * wpin
+	if target <= wmax {
+		target = 2 * wmax
+	}
+	denied := func(fd int, bytes int) error {
+		return privilegeError("SO_SNDBUFFORCE", "CAP_NET_ADMIN", syscall.EPERM)
+	}
+
+	tuner := NewBufferTuner(client, BufferTunerConfig{})
+	if err := getFd(client.(*net.TCPConn), func(fd int) error {
+		pinned, err := tuner.pinBuffer(fd, target, wmax, wpin, setsockoptSendBuffer, denied)
+		if err != nil {
+			return err
+		}
+		actual, err := getsockoptSendBuffer(fd)
+		if err != nil {
+			return err
+		}
+		t.Logf("pinned=%v sndbuf=%d target=%d tcp_wmem=%d wmem_max*2=%d", pinned, actual, target, wmax, wpin)
+		if wpin <= wmax {
+			if pinned {
+				t.Errorf("pinning capped at %d must keep autotune up to %d", wpin, wmax)
+			}
+			return nil
+		}
+		if pinned != true {
+			t.Errorf("pinning up to %d reaches further than autotune %d", wpin, wmax)
+		}
+		if actual != wpin {
+			t.Errorf("read back %d, expect capped at %d", actual, wpin)
+		}
+		return nil
+	}); err != nil {
+		t.Errorf("must no error: %+v", err)
+	}
+}
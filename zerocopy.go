@@ -0,0 +1,355 @@
+package tcpoption
+
+import (
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	DefaultZeroCopyMaxPinned int           = 4 * 1024 * 1024
+	zeroCopyReleasePoll      time.Duration = 100 * time.Millisecond
+)
+
+// EnableZeroCopy sets SO_ZEROCOPY (4.14+), required before sending with MSG_ZEROCOPY
+func EnableZeroCopy(conn net.Conn) error {
+	if c, ok := conn.(*net.TCPConn); ok {
+		return getFd(c, func(fd int) error {
+			return EnableZeroCopyFd(fd)
+		})
+	}
+	return nil
+}
+
+func EnableZeroCopyFd(fd int) error {
+	return setsockoptZeroCopy(fd, 1)
+}
+
+func GetZeroCopy(conn net.Conn) (bool, error) {
+	if c, ok := conn.(*net.TCPConn); ok {
+		enabled := false
+		if err := getFd(c, func(fd int) error {
+			v, err := GetZeroCopyFd(fd)
+			if err != nil {
+				return err
+			}
+			enabled = v
+			return nil
+		}); err != nil {
+			return false, err
+		}
+		return enabled, nil
+	}
+	return false, nil
+}
+
+func GetZeroCopyFd(fd int) (bool, error) {
+	v, err := getsockoptZeroCopy(fd)
+	if err != nil {
+		return false, err
+	}
+	return v == 1, nil
+}
+
+// zeroCopyCompletion is SO_EE_ORIGIN_ZEROCOPY notification, ids lo..hi (inclusive) completed
+type zeroCopyCompletion struct {
+	lo     uint32
+	hi     uint32
+	copied bool // SO_EE_CODE_ZEROCOPY_COPIED
+}
+
+type ZeroCopyStats struct {
+	Sends       uint64 // sendmsg(MSG_ZEROCOPY) calls
+	Completions uint64 // notification ranges read from error queue
+	Copied      uint64 // completions (or plain writes) the kernel served by copying
+	Pinned      int    // bytes not released yet
+}
+
+// FellBack reports that the kernel copied data instead of sending the pages,
+// e.g. loopback, devices without scatter-gather or kernels without SO_ZEROCOPY
+func (s ZeroCopyStats) FellBack() bool {
+	return 0 < s.Copied
+}
+
+type zeroCopyBuf struct {
+	buf     []byte
+	refs    int
+	sending bool
+	owned   bool // passed to Send, handed back through release
+}
+
+// ZeroCopyWriter sends with MSG_ZEROCOPY, the kernel reads pages of the buffers after
+// sendmsg returns so buffers are handed back through release once the completion
+// notifications from the error queue cover them.
+// Send blocks while more than maxPinned bytes wait for completion.
+type ZeroCopyWriter struct {
+	net.Conn
+
+	tcp       *net.TCPConn
+	raw       syscall.RawConn
+	maxPinned int
+	release   func([]byte)
+	enabled   bool
+	mutex     sync.Mutex
+	nextID    uint32
+	inflight  map[uint32]*zeroCopyBuf
+	released  [][]byte
+	stats     ZeroCopyStats
+}
+
+// NewZeroCopyWriter enables SO_ZEROCOPY on conn, release (may be nil) is called with each buffer
+// passed to Send once the kernel no longer references it, from the goroutine calling Send/Write/Flush.
+// on kernels without SO_ZEROCOPY buffers are written by copying and released right away.
+// maxPinned 0 uses DefaultZeroCopyMaxPinned.
+func NewZeroCopyWriter(conn net.Conn, maxPinned int, release func([]byte)) (*ZeroCopyWriter, error) {
+	c, ok := conn.(*net.TCPConn)
+	if ok != true {
+		return nil, errors.New("zerocopy: conn must be *net.TCPConn")
+	}
+	raw, err := c.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	if maxPinned <= 0 {
+		maxPinned = DefaultZeroCopyMaxPinned
+	}
+	if release == nil {
+		release = func([]byte) {}
+	}
+	enabled := true
+	if err := EnableZeroCopy(c); err != nil {
+		if errors.Is(err, syscall.ENOPROTOOPT) != true && errors.Is(err, syscall.EOPNOTSUPP) != true {
+			return nil, err
+		}
+		enabled = false
+	}
+	return &ZeroCopyWriter{
+		Conn:      conn,
+		tcp:       c,
+		raw:       raw,
+		maxPinned: maxPinned,
+		release:   release,
+		enabled:   enabled,
+		inflight:  make(map[uint32]*zeroCopyBuf),
+	}, nil
+}
+
+// Send queues buf without copying, buf must not be modified until it is released.
+func (w *ZeroCopyWriter) Send(buf []byte) error {
+	w.mutex.Lock()
+	defer w.unlock()
+
+	_, _, err := w.sendLocked(buf, true)
+	return err
+}
+
+// Write sends p and waits until the kernel releases it, so p can be reused on return
+// as io.Writer requires. p stays owned by the caller and is never passed to release.
+// when sending fails (e.g. write deadline) after part of p was queued, Write still waits
+// beyond the deadline until the kernel releases p, it returns earlier only when conn is closed.
+// Send pipelines buffers without waiting.
+func (w *ZeroCopyWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.unlock()
+
+	b, n, err := w.sendLocked(p, false)
+	for b != nil && 0 < b.refs {
+		if err == nil {
+			err = w.waitLocked()
+			continue
+		}
+		if perr := w.pollLocked(); perr != nil {
+			return n, err // conn closed
+		}
+	}
+	return n, err
+}
+
+// Flush waits until every buffer is released
+func (w *ZeroCopyWriter) Flush() error {
+	w.mutex.Lock()
+	defer w.unlock()
+
+	for 0 < len(w.inflight) {
+		if err := w.waitLocked(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *ZeroCopyWriter) Close() error {
+	flushErr := w.Flush()
+	if err := w.Conn.Close(); err != nil {
+		return err
+	}
+	return flushErr
+}
+
+func (w *ZeroCopyWriter) Stats() ZeroCopyStats {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return w.stats
+}
+
+// unlock hands released buffers back outside of the lock
+func (w *ZeroCopyWriter) unlock() {
+	released := w.released
+	w.released = nil
+	w.mutex.Unlock()
+
+	for _, buf := range released {
+		w.release(buf)
+	}
+}
+
+// owned buffers are handed to release once sent, Write keeps its buffer.
+// returns the bytes queued, b.refs counts the sendmsg calls not completed yet
+func (w *ZeroCopyWriter) sendLocked(buf []byte, owned bool) (*zeroCopyBuf, int, error) {
+	if len(buf) == 0 {
+		return nil, 0, nil
+	}
+	if w.enabled != true {
+		n, err := w.tcp.Write(buf)
+		w.stats.Copied += 1
+		if owned {
+			w.released = append(w.released, buf)
+		}
+		return nil, n, err
+	}
+
+	// a buffer larger than maxPinned goes out alone
+	for 0 < w.stats.Pinned && w.maxPinned < w.stats.Pinned+len(buf) {
+		if err := w.waitLocked(); err != nil {
+			return nil, 0, err
+		}
+	}
+	if err := w.reapLocked(); err != nil {
+		return nil, 0, err
+	}
+
+	b := &zeroCopyBuf{buf: buf, sending: true, owned: owned}
+	w.stats.Pinned += len(buf)
+	defer func() {
+		b.sending = false
+		w.releaseIfDone(b)
+	}()
+
+	off := 0
+	for off < len(buf) {
+		n, err := w.sendmsg(buf[off:])
+		if errors.Is(err, syscall.ENOBUFS) {
+			// optmem_max exhausted by outstanding notifications
+			if 0 < len(w.inflight) {
+				if err := w.waitLocked(); err != nil {
+					return b, off, err
+				}
+				continue
+			}
+			m, err := w.tcp.Write(buf[off:])
+			w.stats.Copied += 1
+			return b, off + m, err
+		}
+		if err != nil {
+			return b, off, err
+		}
+		// every sendmsg that sent data consumes one notification id
+		w.inflight[w.nextID] = b
+		b.refs += 1
+		w.nextID += 1
+		w.stats.Sends += 1
+		off += n
+	}
+	return b, off, nil
+}
+
+func (w *ZeroCopyWriter) sendmsg(p []byte) (int, error) {
+	n := 0
+	var serr error
+	if err := w.raw.Write(func(fd uintptr) bool {
+		n, serr = sendZeroCopy(int(fd), p)
+		return serr != syscall.EAGAIN
+	}); err != nil {
+		return 0, err
+	}
+	return n, os.NewSyscallError("sendmsg", serr)
+}
+
+func (w *ZeroCopyWriter) reapLocked() error {
+	if err := w.raw.Control(func(fd uintptr) {
+		// errors surface again through waitLocked
+		completions, _ := recvZeroCopyCompletions(int(fd))
+		w.completeLocked(completions)
+	}); err != nil {
+		return err
+	}
+	return nil
+}
+
+// waitLocked blocks until at least one notification arrives, error queue raises EPOLLERR
+func (w *ZeroCopyWriter) waitLocked() error {
+	var rerr error
+	if err := w.raw.Write(func(fd uintptr) bool {
+		completions, err := recvZeroCopyCompletions(int(fd))
+		if err != nil {
+			rerr = os.NewSyscallError("recvmsg", err)
+			return true
+		}
+		if len(completions) == 0 {
+			return false
+		}
+		w.completeLocked(completions)
+		return true
+	}); err != nil {
+		return err
+	}
+	return rerr
+}
+
+// pollLocked waits for notifications ignoring the deadlines of conn, fails only when conn is closed
+func (w *ZeroCopyWriter) pollLocked() error {
+	var perr error
+	if err := w.raw.Control(func(fd uintptr) {
+		if perr = pollErrQueue(int(fd), zeroCopyReleasePoll); perr != nil {
+			return
+		}
+		completions, _ := recvZeroCopyCompletions(int(fd))
+		w.completeLocked(completions)
+	}); err != nil {
+		return err
+	}
+	return perr
+}
+
+func (w *ZeroCopyWriter) completeLocked(completions []zeroCopyCompletion) {
+	for _, c := range completions {
+		w.stats.Completions += 1
+		if c.copied {
+			w.stats.Copied += 1
+		}
+		for id := c.lo; ; id += 1 {
+			if b, ok := w.inflight[id]; ok {
+				delete(w.inflight, id)
+				b.refs -= 1
+				w.releaseIfDone(b)
+			}
+			if id == c.hi {
+				break
+			}
+		}
+	}
+}
+
+func (w *ZeroCopyWriter) releaseIfDone(b *zeroCopyBuf) {
+	if b.sending || 0 < b.refs {
+		return
+	}
+	w.stats.Pinned -= len(b.buf)
+	if b.owned {
+		w.released = append(w.released, b.buf)
+	}
+}
@@ -0,0 +1,250 @@
+package tcpoption
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestZeroCopyEnable(t *testing.T) {
+	client, server := dialPair(t, "tcp4", "127.0.0.1:0", "tcp4")
+	defer client.Close()
+	defer server.Close()
+
+	if err := EnableZeroCopy(client); err != nil {
+		t.Skipf("SO_ZEROCOPY not supported: %+v", err)
+	}
+	enabled, err := GetZeroCopy(client)
+	if err != nil {
+		t.Fatalf("GetZeroCopy err: %+v", err)
+	}
+	if enabled != true {
+		t.Errorf("expect SO_ZEROCOPY enabled")
+	}
+}
+
+func TestZeroCopyWriterNoEarlyRelease(t *testing.T) {
+	const (
+		bufSize   = 64 * 1024
+		maxPinned = 4 * bufSize
+		count     = 256
+	)
+	client, server := dialPair(t, "tcp4", "127.0.0.1:0", "tcp4")
+	defer client.Close()
+	defer server.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		chunk := make([]byte, bufSize)
+		for i := 0; i < count; i += 1 {
+			if _, err := io.ReadFull(server, chunk); err != nil {
+				done <- err
+				return
+			}
+			// released buffers are poisoned with 0xff, early release shows up here
+			if bytes.Count(chunk, []byte{byte(i)}) != bufSize {
+				t.Errorf("chunk %d corrupted: %x...", i, chunk[:16])
+			}
+		}
+		done <- nil
+	}()
+
+	free := make(chan []byte, 2*maxPinned/bufSize)
+	for i := 0; i < cap(free); i += 1 {
+		free <- make([]byte, bufSize)
+	}
+	released := 0
+	w, err := NewZeroCopyWriter(client, maxPinned, func(buf []byte) {
+		for i := range buf {
+			buf[i] = 0xff
+		}
+		released += 1
+		free <- buf
+	})
+	if err != nil {
+		t.Fatalf("NewZeroCopyWriter err: %+v", err)
+	}
+
+	maxSeen := 0
+	for i := 0; i < count; i += 1 {
+		buf := <-free
+		for j := range buf {
+			buf[j] = byte(i)
+		}
+		if err := w.Send(buf); err != nil {
+			t.Fatalf("Send err: %+v", err)
+		}
+		if s := w.Stats(); maxSeen < s.Pinned {
+			maxSeen = s.Pinned
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush err: %+v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("read err: %+v", err)
+	}
+
+	stats := w.Stats()
+	t.Logf("stats = %+v fellback = %v", stats, stats.FellBack())
+	if released != count {
+		t.Errorf("released %d buffers, expect %d", released, count)
+	}
+	if stats.Pinned != 0 {
+		t.Errorf("pinned after Flush = %d", stats.Pinned)
+	}
+	if maxPinned < maxSeen {
+		t.Errorf("pinned %d exceeds %d", maxSeen, maxPinned)
+	}
+}
+
+func TestZeroCopyWriterWrite(t *testing.T) {
+	client, server := dialPair(t, "tcp4", "127.0.0.1:0", "tcp4")
+	defer server.Close()
+
+	payload := bytes.Repeat([]byte("zerocopy"), 128*1024)
+	received := make(chan []byte, 1)
+	go func() {
+		b, _ := io.ReadAll(server)
+		received <- b
+	}()
+
+	w, err := NewZeroCopyWriter(client, 0, nil)
+	if err != nil {
+		t.Fatalf("NewZeroCopyWriter err: %+v", err)
+	}
+	// io.Copy reuses the buffer between Writes
+	if _, err := io.CopyBuffer(w, bytes.NewBuffer(payload), make([]byte, 32*1024)); err != nil {
+		t.Fatalf("copy err: %+v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close err: %+v", err)
+	}
+	if b := <-received; bytes.Equal(b, payload) != true {
+		t.Errorf("payload mismatch: %d bytes received, expect %d", len(b), len(payload))
+	}
+	if s := w.Stats(); s.Pinned != 0 {
+		t.Errorf("pinned after Close = %d", s.Pinned)
+	}
+}
+
+func TestZeroCopyWriterMixedRelease(t *testing.T) {
+	const (
+		bufSize = 64 * 1024
+		count   = 64
+	)
+	client, server := dialPair(t, "tcp4", "127.0.0.1:0", "tcp4")
+	defer client.Close()
+	defer server.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		chunk := make([]byte, bufSize)
+		for i := 0; i < 2*count; i += 1 {
+			if _, err := io.ReadFull(server, chunk); err != nil {
+				done <- err
+				return
+			}
+			if bytes.Count(chunk, []byte{byte(i)}) != bufSize {
+				t.Errorf("chunk %d corrupted: %x...", i, chunk[:16])
+			}
+		}
+		done <- nil
+	}()
+
+	owned := make([]byte, bufSize) // caller owned, reused by every Write like io.Copy
+	released := 0
+	w, err := NewZeroCopyWriter(client, 0, func(buf []byte) {
+		if &buf[0] == &owned[0] {
+			t.Errorf("Write buffer must not be released")
+		}
+		for i := range buf {
+			buf[i] = 0xff
+		}
+		released += 1
+	})
+	if err != nil {
+		t.Fatalf("NewZeroCopyWriter err: %+v", err)
+	}
+
+	for i := 0; i < 2*count; i += 2 {
+		buf := bytes.Repeat([]byte{byte(i)}, bufSize)
+		if err := w.Send(buf); err != nil {
+			t.Fatalf("Send err: %+v", err)
+		}
+		for j := range owned {
+			owned[j] = byte(i + 1)
+		}
+		if _, err := w.Write(owned); err != nil {
+			t.Fatalf("Write err: %+v", err)
+		}
+		if bytes.Count(owned, []byte{byte(i + 1)}) != bufSize {
+			t.Fatalf("Write buffer poisoned after return: %x...", owned[:16])
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush err: %+v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("read err: %+v", err)
+	}
+	if released != count {
+		t.Errorf("released %d buffers, expect %d Send buffers", released, count)
+	}
+}
+
+func TestZeroCopyWriterWriteDeadline(t *testing.T) {
+	client, server := dialPair(t, "tcp4", "127.0.0.1:0", "tcp4")
+	defer client.Close()
+	defer server.Close()
+
+	// more than loopback buffers hold while the receiver is stalled
+	p := make([]byte, 64*1024*1024)
+	for i := range p {
+		p[i] = byte(i % 251) // never 0xff
+	}
+	w, err := NewZeroCopyWriter(client, 0, nil)
+	if err != nil {
+		t.Fatalf("NewZeroCopyWriter err: %+v", err)
+	}
+
+	const stall = 500 * time.Millisecond
+	received := make(chan []byte, 1)
+	go func() {
+		time.Sleep(stall)
+		b, _ := io.ReadAll(server)
+		received <- b
+	}()
+
+	client.SetWriteDeadline(time.Now().Add(100 * time.Millisecond))
+	start := time.Now()
+	n, err := w.Write(p)
+	elapsed := time.Since(start)
+	t.Logf("n=%d err=%v elapsed=%s stats=%+v", n, err, elapsed, w.Stats())
+	if errors.Is(err, os.ErrDeadlineExceeded) != true {
+		t.Fatalf("expect deadline exceeded: n=%d err=%+v", n, err)
+	}
+	if n <= 0 || len(p) <= n {
+		t.Fatalf("expect partial write: %d/%d", n, len(p))
+	}
+	if elapsed < stall {
+		t.Errorf("Write returned after %s while the kernel referenced p until the receiver resumed at %s", elapsed, stall)
+	}
+	// io.Copy reuses p right away
+	for i := range p {
+		p[i] = 0xff
+	}
+	client.(*net.TCPConn).CloseWrite()
+
+	b := <-received
+	if len(b) != n {
+		t.Errorf("received %d bytes, Write reported %d", len(b), n)
+	}
+	if i := bytes.IndexByte(b, 0xff); 0 <= i {
+		t.Errorf("p released early, poisoned byte received at %d", i)
+	}
+}
@@ -0,0 +1,243 @@
+package tcpoption
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+)
+
+const (
+	DefaultZeroCopyReceiveChunk int = 512 * 1024
+)
+
+type ZeroCopyReadStats struct {
+	Mapped uint64 // bytes received through TCP_ZEROCOPY_RECEIVE mapping
+	Copied uint64 // bytes received by read(2), unaligned remainder or fallback
+}
+
+// ZeroCopyReader (experimental) receives with getsockopt(TCP_ZEROCOPY_RECEIVE) (4.18+),
+// page-aligned payload is mapped into mmap of the socket and the rest is read(2) by copying.
+// WriteTo hands the mapped pages to the destination without copying them into userspace buffers,
+// Read still copies out of the mapping.
+// falls back to plain reads when the socket cannot be mapped.
+// close the reader instead of conn, the mapping holds its own reference to the socket file
+// so closing only conn leaks the mapping and keeps the socket open.
+type ZeroCopyReader struct {
+	net.Conn
+
+	tcp     *net.TCPConn
+	raw     syscall.RawConn
+	mutex   sync.Mutex
+	mapping []byte
+	mapped  []byte // unread part of mapping, valid until the next receive
+	skip    int    // bytes the kernel asked to read(2) before the next mapping
+	eof     bool
+	scratch []byte
+	stats   ZeroCopyReadStats
+}
+
+// chunkSize is rounded up to the page size, 0 uses DefaultZeroCopyReceiveChunk
+func NewZeroCopyReader(conn net.Conn, chunkSize int) (*ZeroCopyReader, error) {
+	c, ok := conn.(*net.TCPConn)
+	if ok != true {
+		return nil, errors.New("zerocopy: conn must be *net.TCPConn")
+	}
+	raw, err := c.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	if chunkSize <= 0 {
+		chunkSize = DefaultZeroCopyReceiveChunk
+	}
+	page := os.Getpagesize()
+	chunkSize = (chunkSize + page - 1) / page * page
+
+	r := &ZeroCopyReader{
+		Conn: conn,
+		tcp:  c,
+		raw:  raw,
+	}
+	if err := getFd(c, func(fd int) error {
+		mapping, err := mmapZeroCopyReceive(fd, chunkSize)
+		if err != nil {
+			return err
+		}
+		r.mapping = mapping
+		return nil
+	}); err != nil {
+		if isZeroCopyReceiveUnsupported(err) != true {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+func isZeroCopyReceiveUnsupported(err error) bool {
+	return errors.Is(err, syscall.ENODEV) || // mmap not supported
+		errors.Is(err, syscall.ENOPROTOOPT) ||
+		errors.Is(err, syscall.EOPNOTSUPP) ||
+		errors.Is(err, syscall.EINVAL)
+}
+
+func (r *ZeroCopyReader) Read(p []byte) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if err := r.fillLocked(); err != nil {
+		return 0, err
+	}
+	if 0 < len(r.mapped) {
+		n := copy(p, r.mapped)
+		r.mapped = r.mapped[n:]
+		r.stats.Mapped += uint64(n)
+		return n, nil
+	}
+	return r.copyLocked(p)
+}
+
+// WriteTo writes mapped pages to w directly, w must not retain the slice passed to Write
+func (r *ZeroCopyReader) WriteTo(w io.Writer) (int64, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	total := int64(0)
+	for {
+		if err := r.fillLocked(); err != nil {
+			if err == io.EOF {
+				return total, nil
+			}
+			return total, err
+		}
+		if 0 < len(r.mapped) {
+			n, err := w.Write(r.mapped)
+			r.mapped = r.mapped[n:]
+			r.stats.Mapped += uint64(n)
+			total += int64(n)
+			if err != nil {
+				return total, err
+			}
+			continue
+		}
+		if r.scratch == nil {
+			r.scratch = make([]byte, 64*1024)
+		}
+		n, err := r.copyLocked(r.scratch)
+		if 0 < n {
+			m, werr := w.Write(r.scratch[:n])
+			total += int64(m)
+			if werr != nil {
+				return total, werr
+			}
+		}
+		if err != nil {
+			if err == io.EOF {
+				return total, nil
+			}
+			return total, err
+		}
+	}
+}
+
+func (r *ZeroCopyReader) Stats() ZeroCopyReadStats {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.stats
+}
+
+func (r *ZeroCopyReader) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.mapping != nil {
+		munmapZeroCopyReceive(r.mapping)
+		r.mapping = nil
+		r.mapped = nil
+	}
+	return r.Conn.Close()
+}
+
+// copyLocked reads the unaligned remainder, or everything when mapping is unavailable
+func (r *ZeroCopyReader) copyLocked(p []byte) (int, error) {
+	if 0 < r.skip && r.skip < len(p) {
+		p = p[:r.skip]
+	}
+	n, err := r.tcp.Read(p)
+	r.stats.Copied += uint64(n)
+	if r.skip < n {
+		r.skip = 0
+	} else {
+		r.skip -= n
+	}
+	return n, err
+}
+
+// fillLocked maps next payload when nothing is pending, blocks until data or EOF arrives
+func (r *ZeroCopyReader) fillLocked() error {
+	if 0 < len(r.mapped) || 0 < r.skip || r.mapping == nil {
+		return nil
+	}
+	if r.eof {
+		return io.EOF
+	}
+	for {
+		retry, err := r.receiveLocked()
+		if err != nil && err != io.EOF && isZeroCopyReceiveUnsupported(err) {
+			// kernel refused the mapping, continue with plain reads
+			munmapZeroCopyReceive(r.mapping)
+			r.mapping = nil
+			return nil
+		}
+		if err != nil || retry != true {
+			return err
+		}
+	}
+}
+
+// receiveLocked reports retry when data arrived between TCP_ZEROCOPY_RECEIVE and the peek
+func (r *ZeroCopyReader) receiveLocked() (bool, error) {
+	retry := false
+	var rerr error
+	if err := r.raw.Read(func(fd uintptr) bool {
+		mapped, skip, inq, err := getsockoptZeroCopyReceive(int(fd), r.mapping)
+		// EIO reports empty receive queue after FIN, confirmed by the peek below
+		if err != nil && errors.Is(err, syscall.EIO) != true {
+			rerr = err
+			return true
+		}
+		if 0 < mapped || 0 < skip {
+			r.mapped = r.mapping[:mapped]
+			r.skip = skip
+			return true
+		}
+		if 0 < inq {
+			r.skip = inq
+			return true
+		}
+		// nothing queued, wait for data unless peer has closed
+		n, err := recvPeek(int(fd))
+		if err == syscall.EAGAIN {
+			return false
+		}
+		if err != nil {
+			rerr = os.NewSyscallError("recvfrom", err)
+			return true
+		}
+		if n == 0 {
+			r.eof = true
+			rerr = io.EOF
+			return true
+		}
+		retry = true
+		return true
+	}); err != nil {
+		return false, err
+	}
+	return retry, rerr
+}
@@ -0,0 +1,297 @@
+package tcpoption
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"io"
+	"net"
+	"os"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func zeroCopyPayload(size int) []byte {
+	payload := make([]byte, size)
+	for i := range payload {
+		payload[i] = byte(i * 7)
+	}
+	return payload
+}
+
+func sendPayload(conn net.Conn, payload []byte) chan error {
+	done := make(chan error, 1)
+	go func() {
+		_, err := conn.Write(payload)
+		if err == nil {
+			err = conn.Close()
+		}
+		done <- err
+	}()
+	return done
+}
+
+// zeroCopyMappedPair returns conns with page multiple MSS, payload sent by ZeroCopyWriter from
+// page aligned buffers then arrives in page sized frags which TCP_ZEROCOPY_RECEIVE maps (as tcp_mmap selftest)
+func zeroCopyMappedPair(t testingWrap) (net.Conn, net.Conn) {
+	cfg := Config{MaxSeg: 4 * os.Getpagesize()}
+	listener, err := Listen(context.TODO(), "tcp4", "127.0.0.1:0", cfg)
+	if err != nil {
+		t.Fatalf("listen err: %+v", err)
+	}
+	defer listener.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			close(accepted)
+			return
+		}
+		accepted <- conn
+	}()
+	client, err := Dial(context.TODO(), "tcp4", listener.Addr().String(), cfg)
+	if err != nil {
+		t.Fatalf("dial err: %+v", err)
+	}
+	server, ok := <-accepted
+	if ok != true {
+		t.Fatalf("accept failed")
+	}
+	return client, server
+}
+
+// payload must be page aligned, large allocations are
+func sendZeroCopyPayload(conn net.Conn, payload []byte) chan error {
+	done := make(chan error, 1)
+	go func() {
+		w, err := NewZeroCopyWriter(conn, 0, nil)
+		if err != nil {
+			done <- err
+			return
+		}
+		if _, err = w.Write(payload); err == nil {
+			err = w.Close()
+		}
+		done <- err
+	}()
+	return done
+}
+
+func TestZeroCopyReaderMapped(t *testing.T) {
+	payload := zeroCopyPayload(8*1024*1024 + 123) // unaligned tail
+	expect := sha256.Sum256(payload)
+
+	t.Run("WriteTo", func(tt *testing.T) {
+		client, server := zeroCopyMappedPair(tt)
+		defer client.Close()
+
+		r, err := NewZeroCopyReader(server, 0)
+		if err != nil {
+			tt.Fatalf("NewZeroCopyReader err: %+v", err)
+		}
+		defer r.Close()
+
+		done := sendZeroCopyPayload(client, payload)
+		h := sha256.New()
+		n, err := io.Copy(h, r)
+		if err != nil {
+			tt.Fatalf("copy err: %+v", err)
+		}
+		if err := <-done; err != nil {
+			tt.Fatalf("send err: %+v", err)
+		}
+		if n != int64(len(payload)) || bytes.Equal(h.Sum(nil), expect[:]) != true {
+			tt.Errorf("payload mismatch: %d bytes received, expect %d", n, len(payload))
+		}
+		stats := r.Stats()
+		tt.Logf("stats = %+v", stats)
+		if stats.Mapped+stats.Copied != uint64(len(payload)) {
+			tt.Errorf("mapped %d + copied %d != %d", stats.Mapped, stats.Copied, len(payload))
+		}
+		if stats.Mapped == 0 {
+			tt.Skipf("kernel did not map page aligned payload")
+		}
+	})
+	t.Run("Read", func(tt *testing.T) {
+		client, server := zeroCopyMappedPair(tt)
+		defer client.Close()
+
+		r, err := NewZeroCopyReader(server, 64*1024)
+		if err != nil {
+			tt.Fatalf("NewZeroCopyReader err: %+v", err)
+		}
+		defer r.Close()
+
+		done := sendZeroCopyPayload(client, payload)
+		// short reads copy out of the mapping across several calls
+		b, err := io.ReadAll(struct{ io.Reader }{r})
+		if err != nil {
+			tt.Fatalf("read err: %+v", err)
+		}
+		if err := <-done; err != nil {
+			tt.Fatalf("send err: %+v", err)
+		}
+		if bytes.Equal(b, payload) != true {
+			tt.Errorf("payload mismatch: %d bytes received, expect %d", len(b), len(payload))
+		}
+		stats := r.Stats()
+		tt.Logf("stats = %+v", stats)
+		if stats.Mapped == 0 {
+			tt.Skipf("kernel did not map page aligned payload")
+		}
+		// remainder after the mapping goes through recv_skip_hint
+		if stats.Copied == 0 {
+			tt.Errorf("unaligned tail must be copied: %+v", stats)
+		}
+	})
+	t.Run("Fallback", func(tt *testing.T) {
+		client, server := zeroCopyMappedPair(tt)
+		defer client.Close()
+
+		r, err := NewZeroCopyReader(server, 0)
+		if err != nil {
+			tt.Fatalf("NewZeroCopyReader err: %+v", err)
+		}
+		defer r.Close()
+		if r.mapping == nil {
+			tt.Skipf("socket cannot be mapped")
+		}
+		// anonymous mapping is not a tcp vma, TCP_ZEROCOPY_RECEIVE refuses it with EINVAL
+		anon, err := unix.Mmap(-1, 0, len(r.mapping), unix.PROT_READ, unix.MAP_PRIVATE|unix.MAP_ANON)
+		if err != nil {
+			tt.Fatalf("mmap err: %+v", err)
+		}
+		munmapZeroCopyReceive(r.mapping)
+		r.mapping = anon
+
+		done := sendZeroCopyPayload(client, payload)
+		b, err := io.ReadAll(struct{ io.Reader }{r})
+		if err != nil {
+			tt.Fatalf("read err: %+v", err)
+		}
+		if err := <-done; err != nil {
+			tt.Fatalf("send err: %+v", err)
+		}
+		if bytes.Equal(b, payload) != true {
+			tt.Errorf("payload mismatch: %d bytes received, expect %d", len(b), len(payload))
+		}
+		stats := r.Stats()
+		tt.Logf("stats = %+v", stats)
+		if r.mapping != nil || stats.Mapped != 0 || stats.Copied != uint64(len(payload)) {
+			tt.Errorf("refused mapping must fall back to plain reads: %+v", stats)
+		}
+	})
+}
+
+func TestZeroCopyReader(t *testing.T) {
+	payload := zeroCopyPayload(8*1024*1024 + 123) // unaligned tail
+	expect := sha256.Sum256(payload)
+
+	t.Run("WriteTo", func(tt *testing.T) {
+		client, server := dialPair(tt, "tcp4", "127.0.0.1:0", "tcp4")
+		defer client.Close()
+
+		r, err := NewZeroCopyReader(server, 0)
+		if err != nil {
+			tt.Fatalf("NewZeroCopyReader err: %+v", err)
+		}
+		defer r.Close()
+
+		done := sendPayload(client, payload)
+		h := sha256.New()
+		n, err := io.Copy(h, r)
+		if err != nil {
+			tt.Fatalf("copy err: %+v", err)
+		}
+		if err := <-done; err != nil {
+			tt.Fatalf("send err: %+v", err)
+		}
+		if n != int64(len(payload)) || bytes.Equal(h.Sum(nil), expect[:]) != true {
+			tt.Errorf("payload mismatch: %d bytes received, expect %d", n, len(payload))
+		}
+		// loopback payload sits in compound pages which are never mapped, it goes through recv_skip_hint
+		stats := r.Stats()
+		tt.Logf("stats = %+v", stats)
+		if stats.Mapped+stats.Copied != uint64(len(payload)) {
+			tt.Errorf("mapped %d + copied %d != %d", stats.Mapped, stats.Copied, len(payload))
+		}
+	})
+	t.Run("Read", func(tt *testing.T) {
+		client, server := dialPair(tt, "tcp4", "127.0.0.1:0", "tcp4")
+		defer client.Close()
+
+		r, err := NewZeroCopyReader(server, 64*1024)
+		if err != nil {
+			tt.Fatalf("NewZeroCopyReader err: %+v", err)
+		}
+		defer r.Close()
+
+		done := sendPayload(client, payload)
+		// io.ReadAll uses Read only
+		b, err := io.ReadAll(struct{ io.Reader }{r})
+		if err != nil {
+			tt.Fatalf("read err: %+v", err)
+		}
+		if err := <-done; err != nil {
+			tt.Fatalf("send err: %+v", err)
+		}
+		if bytes.Equal(b, payload) != true {
+			tt.Errorf("payload mismatch: %d bytes received, expect %d", len(b), len(payload))
+		}
+		tt.Logf("stats = %+v", r.Stats())
+	})
+}
+
+func benchmarkReceive(b *testing.B, receive func(net.Conn) (int64, error)) {
+	const size = 4 * 1024 * 1024
+	payload := zeroCopyPayload(size)
+
+	b.SetBytes(size)
+	b.ResetTimer()
+	for i := 0; i < b.N; i += 1 {
+		b.StopTimer()
+		client, server := zeroCopyMappedPair(b)
+		done := sendZeroCopyPayload(client, payload)
+		b.StartTimer()
+
+		n, err := receive(server)
+		if err != nil {
+			b.Fatalf("receive err: %+v", err)
+		}
+		if n != size {
+			b.Fatalf("received %d bytes, expect %d", n, size)
+		}
+
+		b.StopTimer()
+		if err := <-done; err != nil {
+			b.Fatalf("send err: %+v", err)
+		}
+		server.Close()
+		b.StartTimer()
+	}
+}
+
+func BenchmarkZeroCopyReader(b *testing.B) {
+	b.Run("read", func(tb *testing.B) {
+		buf := make([]byte, 256*1024)
+		benchmarkReceive(tb, func(conn net.Conn) (int64, error) {
+			return io.CopyBuffer(io.Discard, struct{ io.Reader }{conn}, buf)
+		})
+	})
+	b.Run("zerocopy", func(tb *testing.B) {
+		mapped := uint64(0)
+		benchmarkReceive(tb, func(conn net.Conn) (int64, error) {
+			r, err := NewZeroCopyReader(conn, 256*1024)
+			if err != nil {
+				return 0, err
+			}
+			defer r.Close() // releases the mapping, closing conn alone keeps the socket open
+			n, err := io.Copy(io.Discard, r)
+			mapped += r.Stats().Mapped
+			return n, err
+		})
+		tb.ReportMetric(float64(mapped)/float64(tb.N), "mapped/op")
+	})
+}